@@ -5,12 +5,14 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"youtube-bot-simple/internal/config"
 	"youtube-bot-simple/internal/downloader"
 	"youtube-bot-simple/internal/files"
+	"youtube-bot-simple/internal/i18n"
 	"youtube-bot-simple/internal/queue"
 	"youtube-bot-simple/internal/state"
 	"youtube-bot-simple/internal/telegram"
@@ -27,29 +29,109 @@ func main() {
 		log.Fatalf("failed to ensure download dir: %v", err)
 	}
 
-	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	// свой сервер telegram-bot-api (BOT_API_URL) или публичный
+	endpoint := tgbotapi.APIEndpoint
+	if cfg.BotAPIURL != "" {
+		endpoint = cfg.BotAPIURL + "/bot%s/%s"
+	}
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.TelegramToken, endpoint)
 	if err != nil {
 		log.Fatalf("failed to init bot api: %v", err)
 	}
 	api.Debug = false
-	log.Printf("[bot] authorized on account %s", api.Self.UserName)
+	log.Printf("[bot] authorized on account %s (upload limit %d MB, local api=%v)", api.Self.UserName, cfg.MaxFileMB, cfg.LocalBotAPI)
+	log.Printf("[bot] %d download preset(s) loaded", len(cfg.PresetRegistry().All()))
 
 	st := state.NewStore()
 	q := queue.NewQueue(cfg.QueueCapacity, cfg.Concurrency)
+	journal, err := queue.OpenJournal(filepath.Join(cfg.StateDir, "jobs.json"))
+	if err != nil {
+		log.Fatalf("failed to open job journal: %v", err)
+	}
+	q.UseJournal(journal)
 	dl := downloader.NewRunner(cfg)
 
+	// проверка yt-dlp/ffmpeg до приёма первых задач
+	var report *downloader.Report
+	if cfg.PreflightMode != "off" {
+		report = dl.Preflight(context.Background())
+		logLang := i18n.Default().For("en") // логи пишутся по-английски
+		for _, p := range report.Problems {
+			log.Printf("[preflight] %s", p.Text(logLang))
+		}
+		if !report.OK() && cfg.PreflightMode == "strict" {
+			log.Fatalf("preflight failed (mode=%s): %d problem(s)", cfg.PreflightMode, len(report.Problems))
+		}
+		// деградированный режим: убрать с клавиатуры пресеты, которые не выполнить без ffmpeg/кодеков
+		if !report.OK() {
+			cfg.Presets = cfg.Presets.Filter(report.Supports)
+			log.Printf("[preflight] degraded mode: %d preset(s) available", len(cfg.Presets.All()))
+		}
+		log.Printf("[preflight] yt-dlp=%s ffmpeg=%s ffprobe=%s extractors=%d", report.YtDlp.Version, report.FFmpeg.Version, report.FFprobe.Version, report.Extractors)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// GC временного стора и очистка файлов
 	st.StartGC(ctx, 5*time.Minute)
 	files.StartCleanup(ctx, cfg.DownloadDir, cfg.CleanupTTLHours)
+	workRoot := filepath.Join(cfg.DownloadDir, downloader.WorkDirName)
+	if cfg.PartialTTLHours > 0 {
+		if n, err := files.CleanupStaleDirs(workRoot, time.Duration(cfg.PartialTTLHours)*time.Hour); err == nil && n > 0 {
+			log.Printf("[cleanup] removed %d abandoned partial download(s)", n)
+		}
+	}
+	files.StartPartialCleanup(ctx, workRoot, cfg.PartialTTLHours)
+	dl.Budget().Start(ctx)
+
+	// все исходящие вызовы — через лимиты Telegram
+	out := telegram.NewOutbox(ctx, api, cfg.SendRateGlobal, cfg.SendRateChat, cfg.SendRateGroup)
+	b := telegram.NewBot(out, cfg, st, q, dl)
+	b.UseUsername(api.Self.UserName)
+	prefs, err := state.OpenPrefs(filepath.Join(cfg.StateDir, "prefs.json"))
+	if err != nil {
+		log.Fatalf("failed to open user prefs: %v", err)
+	}
+	b.UsePrefs(prefs)
+	fileCache, err := state.OpenFileCache(filepath.Join(cfg.StateDir, "files.json"))
+	if err != nil {
+		log.Fatalf("failed to open file cache: %v", err)
+	}
+	b.UseFileCache(fileCache)
 
-	b := telegram.NewBot(api, cfg, st, q, dl)
+	// rate limit YouTube: пауза очереди и уведомление админов
+	dl.Breaker().OnOpen(func(until time.Time, kind downloader.ErrorKind) {
+		log.Printf("[bot] circuit breaker open (%s) until %s", kind, until.Format(time.RFC3339))
+		q.Pause(until)
+		b.NotifyAdmins(func(l i18n.Localizer) string {
+			return l.T("admin.rate_limited", "kind", kind, "time", until.Format("15:04"))
+		})
+	})
+
+	if report != nil && !report.OK() {
+		b.NotifyAdmins(func(l i18n.Localizer) string {
+			return l.T("admin.degraded", "report", report.Text(l))
+		})
+	}
 
 	// запуск воркеров очереди
 	q.Start(ctx, b.Worker)
 
+	// задачи, прерванные прошлым остановом; падавшие раз за разом и устаревшие — отбрасываются
+	resume, dropped, err := journal.Resume(time.Duration(cfg.PartialTTLHours) * time.Hour)
+	if err != nil {
+		log.Printf("[bot] journal resume failed: %v", err)
+	}
+	if len(dropped) > 0 {
+		log.Printf("[bot] dropping %d job(s) that did not finish after restarts", len(dropped))
+		b.GiveUp(dropped)
+	}
+	if len(resume) > 0 {
+		log.Printf("[bot] resuming %d interrupted job(s)", len(resume))
+		go b.Resume(resume)
+	}
+
 	// graceful shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
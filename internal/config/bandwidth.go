@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthWindow — окно суток со своим лимитом скорости (байт/с, 0 — без лимита)
+type BandwidthWindow struct {
+	From, To int // минуты от начала суток; From > To — окно через полночь
+	Rate     int64
+}
+
+// Contains — попадает ли время в окно
+func (w BandwidthWindow) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.From <= w.To {
+		return m >= w.From && m < w.To
+	}
+	return m >= w.From || m < w.To
+}
+
+// BandwidthAt — лимит на момент t: первое подходящее окно расписания или общий лимит
+func (c *Config) BandwidthAt(t time.Time) int64 {
+	for _, w := range c.BandwidthSchedule {
+		if w.Contains(t) {
+			return w.Rate
+		}
+	}
+	return c.BandwidthLimit
+}
+
+// ParseRate — «2M», «512K», «1048576» → байт/с; пусто или 0 — без лимита
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1024, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1024*1024, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1024*1024*1024, strings.TrimSuffix(s, "G")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// ParseSchedule — «09:00-18:00=1M;18:00-09:00=0»
+func ParseSchedule(s string) ([]BandwidthWindow, error) {
+	var out []BandwidthWindow
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		span, rate, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q", part)
+		}
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule span %q", span)
+		}
+		w := BandwidthWindow{}
+		var err error
+		if w.From, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.To, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.Rate, err = ParseRate(rate); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
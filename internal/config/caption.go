@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// CaptionFields — поля шаблона подписи ({title}, {link}, ...); значения подставляет бот
+var CaptionFields = []string{"title", "channel", "duration", "resolution", "size", "url", "link", "info", "chapters", "sponsorblock"}
+
+// CaptionFieldRe — поле в шаблоне подписи
+var CaptionFieldRe = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// MaxCaptionTemplate — длиннее шаблон всё равно не уложится в подпись Telegram
+const MaxCaptionTemplate = 1024
+
+// CheckCaption — шаблон подписи: не длиннее подписи и только известные поля;
+// подстановка без циклов и условий, так что итог не больше суммы полей
+func CheckCaption(tmpl string) error {
+	if strings.Contains(tmpl, "{{") {
+		return fmt.Errorf("Go templates are not supported, use fields like {title}")
+	}
+	if n := utf8.RuneCountInString(tmpl); n > MaxCaptionTemplate {
+		return fmt.Errorf("template is %d characters long (max %d)", n, MaxCaptionTemplate)
+	}
+	for _, m := range CaptionFieldRe.FindAllStringSubmatch(tmpl, -1) {
+		if !knownCaptionField(m[1]) {
+			return fmt.Errorf("unknown field {%s} (%s)", m[1], strings.Join(CaptionFields, "|"))
+		}
+	}
+	return nil
+}
+
+func knownCaptionField(name string) bool {
+	for _, f := range CaptionFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config — общая конфигурация приложения
@@ -15,39 +16,196 @@ import (
 // максимальная простота без DI и БД
 
 type Config struct {
-	TelegramToken   string
-	DownloadDir     string
-	YtDlpPath       string
-	FFmpegPath      string
-	HTTPProxy       string
-	Concurrency     int
-	QueueCapacity   int
-	MaxFileMB       int64
-	CleanupTTLHours int
-	CmdTimeoutSec   int
+	TelegramToken      string
+	DownloadDir        string
+	YtDlpPath          string
+	FFmpegPath         string
+	HTTPProxy          string
+	ProxyList          []string
+	ProxyStrategy      string
+	ProxyCooldownSec   int
+	Concurrency        int
+	QueueCapacity      int
+	MaxFileMB          int64
+	CleanupTTLHours    int
+	CmdTimeoutSec      int
+	AdminIDs           []int64
+	BreakerThreshold   int
+	BreakerWindowSec   int
+	BreakerCooldownSec int
+	LiveMaxMinutes     int
+	YtDlpMinVersion    string
+	PreflightMode      string
+	BandwidthLimit     int64 // байт/с на всех воркеров, 0 — без лимита
+	BandwidthSchedule  []BandwidthWindow
+	StateDir           string
+	PartialTTLHours    int
+	SponsorBlockAPI    string
+	PostProcessTimeout time.Duration // таймаут шага постобработки по умолчанию
+	PresetsFile        string
+	CodecMode          string // any|prefer|transcode — совместимость видео с Telegram
+	CaptionTemplate    string // поля {title}, {link}, ...; пусто — встроенный шаблон
+	CaptionParseMode   string // HTML|MarkdownV2|plain
+	InlineCacheChat    int64  // чат для загрузки файлов инлайн-режима; 0 — личка пользователя
+	WebhookURL         string // публичный https-адрес; пусто — long polling
+	WebhookListen      string
+	WebhookPath        string // секретный путь приёма обновлений
+	WebhookSecret      string // X-Telegram-Bot-Api-Secret-Token
+	WebhookCert        string // TLS без обратного прокси; самоподписанный сертификат уходит в setWebhook
+	WebhookKey         string
+	BotAPIURL          string // свой сервер telegram-bot-api; пусто — api.telegram.org
+	LocalBotAPI        bool   // сервер запущен с --local: файлы по пути, до 2000 МБ
+	SendRateGlobal     int    // исходящих сообщений в секунду на всего бота
+	SendRateChat       int    // в секунду в один личный чат
+	SendRateGroup      int    // в минуту в одну группу
+	UpdateWorkers      int    // сколько чатов обрабатываются одновременно
+	Presets            *Presets
 }
 
+// лимиты отправки файла ботом, МБ: api.telegram.org и локальный telegram-bot-api --local
+const (
+	PublicUploadMB = 50
+	LocalUploadMB  = 2000
+)
+
+// режимы CODEC_MODE: как есть; предпочитать H.264/AAC; ещё и перекодировать VP9/AV1
+const (
+	CodecAny       = "any"
+	CodecPrefer    = "prefer"
+	CodecTranscode = "transcode"
+)
+
 // Load — загрузка конфигурации из окружения (+ .env если есть)
 func Load() (*Config, error) {
 	_ = loadDotEnv(".env") // необязательно
 
 	cfg := &Config{
-		TelegramToken:   strings.TrimSpace(os.Getenv("TELEGRAM_TOKEN")),
-		DownloadDir:     firstNonEmpty(os.Getenv("DOWNLOAD_DIR"), "./downloads"),
-		YtDlpPath:       strings.TrimSpace(os.Getenv("YTDLP_PATH")),
-		FFmpegPath:      strings.TrimSpace(os.Getenv("FFMPEG_PATH")),
-		HTTPProxy:       strings.TrimSpace(os.Getenv("HTTP_PROXY")),
-		Concurrency:     atoiDefault(os.Getenv("CONCURRENCY"), 2),
-		QueueCapacity:   atoiDefault(os.Getenv("QUEUE_CAPACITY"), 100),
-		MaxFileMB:       atoi64Default(os.Getenv("MAX_FILE_MB"), 45),
-		CleanupTTLHours: atoiDefault(os.Getenv("CLEANUP_TTL_HOURS"), 12),
-		CmdTimeoutSec:   atoiDefault(os.Getenv("CMD_TIMEOUT_SEC"), 600),
+		TelegramToken:      strings.TrimSpace(os.Getenv("TELEGRAM_TOKEN")),
+		DownloadDir:        firstNonEmpty(os.Getenv("DOWNLOAD_DIR"), "./downloads"),
+		YtDlpPath:          strings.TrimSpace(os.Getenv("YTDLP_PATH")),
+		FFmpegPath:         strings.TrimSpace(os.Getenv("FFMPEG_PATH")),
+		HTTPProxy:          strings.TrimSpace(os.Getenv("HTTP_PROXY")),
+		ProxyStrategy:      firstNonEmpty(os.Getenv("PROXY_STRATEGY"), "round-robin"),
+		ProxyCooldownSec:   atoiDefault(os.Getenv("PROXY_COOLDOWN_SEC"), 300),
+		Concurrency:        atoiDefault(os.Getenv("CONCURRENCY"), 2),
+		QueueCapacity:      atoiDefault(os.Getenv("QUEUE_CAPACITY"), 100),
+		CleanupTTLHours:    atoiDefault(os.Getenv("CLEANUP_TTL_HOURS"), 12),
+		CmdTimeoutSec:      atoiDefault(os.Getenv("CMD_TIMEOUT_SEC"), 600),
+		BreakerThreshold:   atoiDefault(os.Getenv("BREAKER_THRESHOLD"), 3),
+		BreakerWindowSec:   atoiDefault(os.Getenv("BREAKER_WINDOW_SEC"), 300),
+		BreakerCooldownSec: atoiDefault(os.Getenv("BREAKER_COOLDOWN_SEC"), 900),
+		LiveMaxMinutes:     atoiDefault(os.Getenv("LIVE_MAX_MINUTES"), 30),
+		YtDlpMinVersion:    firstNonEmpty(os.Getenv("YTDLP_MIN_VERSION"), "2024.01.01"),
+		PreflightMode:      firstNonEmpty(os.Getenv("PREFLIGHT_MODE"), "degraded"),
+		StateDir:           firstNonEmpty(os.Getenv("STATE_DIR"), "./data"),
+		PartialTTLHours:    atoiDefault(os.Getenv("PARTIAL_TTL_HOURS"), 24),
+		SponsorBlockAPI:    strings.TrimSpace(os.Getenv("SPONSORBLOCK_API")),
+		PostProcessTimeout: time.Duration(atoiDefault(os.Getenv("POSTPROCESS_TIMEOUT_SEC"), 600)) * time.Second,
+		PresetsFile:        strings.TrimSpace(os.Getenv("PRESETS_FILE")),
+		CodecMode:          firstNonEmpty(os.Getenv("CODEC_MODE"), CodecPrefer),
+		CaptionTemplate:    strings.ReplaceAll(os.Getenv("CAPTION_TEMPLATE"), `\n`, "\n"),
+		CaptionParseMode:   firstNonEmpty(os.Getenv("CAPTION_PARSE_MODE"), "HTML"),
+		WebhookURL:         strings.TrimRight(strings.TrimSpace(os.Getenv("WEBHOOK_URL")), "/"),
+		WebhookListen:      firstNonEmpty(os.Getenv("WEBHOOK_LISTEN"), ":8443"),
+		WebhookPath:        strings.TrimSpace(os.Getenv("WEBHOOK_PATH")),
+		WebhookSecret:      strings.TrimSpace(os.Getenv("WEBHOOK_SECRET")),
+		WebhookCert:        strings.TrimSpace(os.Getenv("WEBHOOK_CERT")),
+		WebhookKey:         strings.TrimSpace(os.Getenv("WEBHOOK_KEY")),
+		BotAPIURL:          strings.TrimRight(strings.TrimSpace(os.Getenv("BOT_API_URL")), "/"),
+		LocalBotAPI:        os.Getenv("BOT_API_LOCAL") == "true",
+		SendRateGlobal:     atoiDefault(os.Getenv("SEND_RATE_GLOBAL"), 25),
+		SendRateChat:       atoiDefault(os.Getenv("SEND_RATE_CHAT"), 1),
+		SendRateGroup:      atoiDefault(os.Getenv("SEND_RATE_GROUP"), 20),
+		UpdateWorkers:      atoiDefault(os.Getenv("UPDATE_WORKERS"), 8),
+	}
+
+	// лимит отправки зависит от сервера Bot API: публичный — 50 МБ, локальный — 2000 МБ
+	if cfg.LocalBotAPI && cfg.BotAPIURL == "" {
+		return nil, errors.New("BOT_API_LOCAL requires BOT_API_URL")
+	}
+	limit, def := int64(PublicUploadMB), int64(45)
+	if cfg.LocalBotAPI {
+		limit, def = LocalUploadMB, LocalUploadMB
+	}
+	cfg.MaxFileMB = atoi64Default(os.Getenv("MAX_FILE_MB"), def)
+	if cfg.MaxFileMB > limit {
+		cfg.MaxFileMB = limit
 	}
 
 	if cfg.TelegramToken == "" {
 		return nil, errors.New("TELEGRAM_TOKEN is required")
 	}
 
+	// пул прокси: HTTP_PROXY + PROXY_LIST + PROXY_FILE
+	cfg.ProxyList = splitList(os.Getenv("PROXY_LIST"))
+	if pf := strings.TrimSpace(os.Getenv("PROXY_FILE")); pf != "" {
+		lines, err := readLines(pf)
+		if err != nil {
+			return nil, fmt.Errorf("read proxy file: %w", err)
+		}
+		cfg.ProxyList = append(cfg.ProxyList, lines...)
+	}
+	if cfg.HTTPProxy != "" {
+		cfg.ProxyList = append([]string{cfg.HTTPProxy}, cfg.ProxyList...)
+	}
+
+	switch cfg.PreflightMode {
+	case "strict", "degraded", "off":
+	default:
+		return nil, fmt.Errorf("invalid PREFLIGHT_MODE %q (strict|degraded|off)", cfg.PreflightMode)
+	}
+
+	switch cfg.CodecMode {
+	case CodecAny, CodecPrefer, CodecTranscode:
+	default:
+		return nil, fmt.Errorf("invalid CODEC_MODE %q (%s|%s|%s)", cfg.CodecMode, CodecAny, CodecPrefer, CodecTranscode)
+	}
+
+	switch cfg.CaptionParseMode {
+	case "HTML", "MarkdownV2", "plain":
+	default:
+		return nil, fmt.Errorf("invalid CAPTION_PARSE_MODE %q (HTML|MarkdownV2|plain)", cfg.CaptionParseMode)
+	}
+	if err := CheckCaption(cfg.CaptionTemplate); err != nil {
+		return nil, fmt.Errorf("CAPTION_TEMPLATE: %w", err)
+	}
+
+	var err error
+	if cfg.BandwidthLimit, err = ParseRate(os.Getenv("BANDWIDTH_LIMIT")); err != nil {
+		return nil, fmt.Errorf("BANDWIDTH_LIMIT: %w", err)
+	}
+	if cfg.BandwidthSchedule, err = ParseSchedule(os.Getenv("BANDWIDTH_SCHEDULE")); err != nil {
+		return nil, fmt.Errorf("BANDWIDTH_SCHEDULE: %w", err)
+	}
+	if cfg.Presets, err = LoadPresets(cfg.PresetsFile); err != nil {
+		return nil, fmt.Errorf("PRESETS_FILE: %w", err)
+	}
+	cfg.Presets = cfg.Presets.Available(cfg.LocalBotAPI)
+
+	if cfg.LiveMaxMinutes <= 0 {
+		cfg.LiveMaxMinutes = 30
+	}
+
+	for _, id := range splitList(os.Getenv("ADMIN_IDS")) {
+		v, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ADMIN_IDS entry %q", id)
+		}
+		cfg.AdminIDs = append(cfg.AdminIDs, v)
+	}
+
+	if err := cfg.checkWebhook(); err != nil {
+		return nil, err
+	}
+
+	if v := strings.TrimSpace(os.Getenv("INLINE_CACHE_CHAT")); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INLINE_CACHE_CHAT %q", v)
+		}
+		cfg.InlineCacheChat = id
+	}
+
 	// создать директорию загрузок
 	if err := os.MkdirAll(cfg.DownloadDir, 0o755); err != nil {
 		return nil, fmt.Errorf("create download dir: %w", err)
@@ -59,9 +217,24 @@ func Load() (*Config, error) {
 		cfg.DownloadDir = d
 	}
 
+	// состояние между перезапусками (журнал задач и т.п.)
+	if err := os.MkdirAll(cfg.StateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create state dir: %w", err)
+	}
+
 	return cfg, nil
 }
 
+// IsAdmin — пользователь/чат из списка ADMIN_IDS
+func (c *Config) IsAdmin(id int64) bool {
+	for _, a := range c.AdminIDs {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
 // loadDotEnv — простая загрузка .env без внешних зависимостей
 func loadDotEnv(path string) error {
 	f, err := os.Open(path)
@@ -89,6 +262,31 @@ func loadDotEnv(path string) error {
 	return s.Err()
 }
 
+// readLines — непустые строки файла без комментариев
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out, s.Err()
+}
+
+// splitList — разбор списка через запятую/пробелы
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == ';' })
+}
+
 func firstNonEmpty(a, b string) string {
 	if strings.TrimSpace(a) != "" {
 		return a
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PostSteps — шаги постобработки, которые умеет загрузчик
+var PostSteps = []string{"loudnorm", "remux", "reencode", "thumbnail", "trimsilence", "metadata", "compat"}
+
+// PostStep — шаг постобработки; Timeout 0 — общий POSTPROCESS_TIMEOUT_SEC
+type PostStep struct {
+	Name    string
+	Timeout time.Duration
+}
+
+// parseSteps — «remux,loudnorm:600»; пустой список допустим (постобработка выключена)
+func parseSteps(s string) ([]PostStep, error) {
+	var steps []PostStep
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, sec, hasTimeout := strings.Cut(item, ":")
+		if !knownStep(name) {
+			return nil, fmt.Errorf("unknown step %q (%s)", name, strings.Join(PostSteps, "|"))
+		}
+		st := PostStep{Name: name}
+		if hasTimeout {
+			n, err := strconv.Atoi(sec)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("step %q: bad timeout %q", name, sec)
+			}
+			st.Timeout = time.Duration(n) * time.Second
+		}
+		steps = append(steps, st)
+	}
+	return steps, nil
+}
+
+func knownStep(name string) bool {
+	for _, s := range PostSteps {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
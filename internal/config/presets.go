@@ -0,0 +1,221 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// способы отправки готового файла
+const (
+	SendVideo    = "video"
+	SendAudio    = "audio"
+	SendDocument = "document"
+)
+
+// Preset — вариант загрузки: кнопка, параметры yt-dlp, постобработка и способ отправки
+type Preset struct {
+	ID          string            `json:"id"`             // хранится в задаче и в имени файла
+	Code        string            `json:"code,omitempty"` // в callback data кнопки (лимит 64 байта); по умолчанию = ID
+	Label       string            `json:"label"`
+	Labels      map[string]string `json:"labels,omitempty"` // название на других языках: {"en": "Audio MP3"}
+	Row         int               `json:"row"`              // строка клавиатуры, кнопки одной строки — в порядке файла
+	Format      string            `json:"format"`
+	MergeFormat string            `json:"merge_format,omitempty"`
+	ExtraArgs   []string          `json:"extra_args,omitempty"`
+	PostProcess []string          `json:"postprocess,omitempty"` // «remux», «loudnorm:600»
+	Send        string            `json:"send"`
+	LocalOnly   bool              `json:"local_only,omitempty"` // только с локальным Bot API: публичный не примет такой файл
+
+	Steps []PostStep `json:"-"`
+}
+
+// LabelIn — название кнопки на языке lang; нет перевода — Label
+func (p Preset) LabelIn(lang string) string {
+	if l := p.Labels[lang]; l != "" {
+		return l
+	}
+	return p.Label
+}
+
+// Audio — пресет без видео (шаги постобработки собирают mp3)
+func (p Preset) Audio() bool { return p.Send == SendAudio }
+
+// Presets — реестр пресетов: по нему строятся клавиатура, разбор callback и аргументы yt-dlp
+
+type Presets struct {
+	list   []Preset
+	byID   map[string]int
+	byCode map[string]int
+}
+
+// встроенные пресеты — прежние варианты 360p/720p/1080p/1440p/MP3 и 4K для локального Bot API
+var builtinPresets = []Preset{
+	{ID: "video360", Code: "360", Label: "Видео 360p", Labels: map[string]string{"en": "Video 360p"}, Row: 0, Format: "bv*[height<=360]+ba/b[ext=mp4]/best[height<=360]", MergeFormat: "mp4", PostProcess: []string{"remux"}, Send: SendVideo},
+	{ID: "video720", Code: "720", Label: "HD 720p", Row: 0, Format: "bv*[height<=720]+ba/b[ext=mp4]/best[height<=720]", MergeFormat: "mp4", PostProcess: []string{"remux"}, Send: SendVideo},
+	{ID: "video1080", Code: "1080", Label: "Full HD 1080p", Row: 1, Format: "bv*[height<=1080]+ba/b[ext=mp4]/best[height<=1080]", MergeFormat: "mp4", PostProcess: []string{"remux"}, Send: SendVideo},
+	{ID: "video1440", Code: "1440", Label: "2K 1440p", Row: 1, Format: "bv*[height<=1440]+ba/b[ext=mp4]/best[height<=1440]", MergeFormat: "mp4", PostProcess: []string{"remux"}, Send: SendVideo},
+	{ID: "video2160", Code: "2160", Label: "4K 2160p", Row: 1, Format: "bv*[height<=2160]+ba/b[ext=mp4]/best[height<=2160]", MergeFormat: "mp4", PostProcess: []string{"remux"}, Send: SendVideo, LocalOnly: true},
+	{ID: "audioMp3", Code: "mp3", Label: "Аудио MP3", Labels: map[string]string{"en": "Audio MP3"}, Row: 2, Format: "ba/b", ExtraArgs: []string{"-x", "--audio-format", "mp3"}, Send: SendAudio},
+}
+
+// допустимые ID и коды: попадают в callback data и имена файлов
+var presetIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// DefaultPresets — встроенный реестр
+func DefaultPresets() *Presets {
+	p, err := NewPresets(builtinPresets)
+	if err != nil {
+		panic("builtin presets: " + err.Error())
+	}
+	return p
+}
+
+// LoadPresets — пресеты из JSON-файла {"presets": [...]}; пустой путь — встроенные
+func LoadPresets(path string) (*Presets, error) {
+	if path == "" {
+		return DefaultPresets(), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Presets []Preset `json:"presets"`
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p, err := NewPresets(file.Presets)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// NewPresets — проверить список и построить реестр
+func NewPresets(list []Preset) (*Presets, error) {
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no presets defined")
+	}
+	p := &Presets{byID: make(map[string]int), byCode: make(map[string]int)}
+	for i, pr := range list {
+		if pr.Code == "" {
+			pr.Code = pr.ID
+		}
+		where := fmt.Sprintf("preset #%d (%s)", i+1, pr.ID)
+		switch {
+		case !presetIDRe.MatchString(pr.ID):
+			return nil, fmt.Errorf("%s: id must match %s", where, presetIDRe)
+		case !presetIDRe.MatchString(pr.Code):
+			return nil, fmt.Errorf("%s: code must match %s", where, presetIDRe)
+		case strings.HasPrefix(pr.Code, "live"):
+			return nil, fmt.Errorf("%s: code prefix \"live\" is reserved for stream recording", where)
+		case strings.TrimSpace(pr.Label) == "":
+			return nil, fmt.Errorf("%s: empty label", where)
+		case strings.TrimSpace(pr.Format) == "":
+			return nil, fmt.Errorf("%s: empty format", where)
+		case pr.Row < 0:
+			return nil, fmt.Errorf("%s: negative row", where)
+		}
+		switch pr.Send {
+		case SendVideo, SendAudio, SendDocument:
+		default:
+			return nil, fmt.Errorf("%s: send must be %s|%s|%s", where, SendVideo, SendAudio, SendDocument)
+		}
+		if _, dup := p.byID[pr.ID]; dup {
+			return nil, fmt.Errorf("%s: duplicate id", where)
+		}
+		if _, dup := p.byCode[pr.Code]; dup {
+			return nil, fmt.Errorf("%s: duplicate code %q", where, pr.Code)
+		}
+		steps, err := parseSteps(strings.Join(pr.PostProcess, ","))
+		if err != nil {
+			return nil, fmt.Errorf("%s: postprocess: %w", where, err)
+		}
+		pr.Steps = steps
+		p.byID[pr.ID] = len(p.list)
+		p.byCode[pr.Code] = len(p.list)
+		p.list = append(p.list, pr)
+	}
+	return p, nil
+}
+
+// All — пресеты в порядке объявления
+func (p *Presets) All() []Preset { return append([]Preset(nil), p.list...) }
+
+// Get — пресет по ID (из задачи)
+func (p *Presets) Get(id string) (Preset, bool) {
+	i, ok := p.byID[id]
+	if !ok {
+		return Preset{}, false
+	}
+	return p.list[i], true
+}
+
+// ByCode — пресет по коду из callback data
+func (p *Presets) ByCode(code string) (Preset, bool) {
+	i, ok := p.byCode[code]
+	if !ok {
+		return Preset{}, false
+	}
+	return p.list[i], true
+}
+
+// Rows — пресеты, разложенные по строкам клавиатуры
+func (p *Presets) Rows() [][]Preset {
+	byRow := make(map[int][]Preset)
+	for _, pr := range p.list {
+		byRow[pr.Row] = append(byRow[pr.Row], pr)
+	}
+	keys := make([]int, 0, len(byRow))
+	for k := range byRow {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	rows := make([][]Preset, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, byRow[k])
+	}
+	return rows
+}
+
+// Available — пресеты, которые можно отправить: без локального Bot API — без local_only
+func (p *Presets) Available(local bool) *Presets {
+	if local {
+		return p
+	}
+	return p.Filter(func(pr Preset) bool { return !pr.LocalOnly })
+}
+
+// Filter — пресеты, для которых keep вернул true
+func (p *Presets) Filter(keep func(Preset) bool) *Presets {
+	var list []Preset
+	for _, pr := range p.list {
+		if keep(pr) {
+			list = append(list, pr)
+		}
+	}
+	if len(list) == len(p.list) {
+		return p
+	}
+	// список уже проверен; пустой — оставляем как есть, чтобы не остаться без кнопок
+	reg, err := NewPresets(list)
+	if err != nil {
+		return p
+	}
+	return reg
+}
+
+// PresetRegistry — реестр из PRESETS_FILE; без Load (в тестах) — встроенный
+func (c *Config) PresetRegistry() *Presets {
+	if c.Presets == nil {
+		return DefaultPresets().Available(c.LocalBotAPI)
+	}
+	return c.Presets
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPresets(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "presets.json")
+	data := `{"presets": [
+		{"id": "v480", "label": "480p", "row": 1, "format": "bv*[height<=480]+ba/b", "merge_format": "mp4", "send": "video"},
+		{"id": "opus", "code": "op", "label": "Opus", "row": 0, "format": "ba", "extra_args": ["-x", "--audio-format", "opus"], "postprocess": ["loudnorm:120"], "send": "audio"}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := LoadPresets(path)
+	if err != nil {
+		t.Fatalf("LoadPresets: %v", err)
+	}
+	p, ok := reg.ByCode("op")
+	if !ok || p.ID != "opus" || len(p.Steps) != 1 || p.Steps[0].Timeout.Seconds() != 120 {
+		t.Fatalf("ByCode(op) = %+v, %v", p, ok)
+	}
+	if p, ok := reg.ByCode("v480"); !ok || p.Code != "v480" {
+		t.Fatalf("code should default to id: %+v, %v", p, ok)
+	}
+	rows := reg.Rows()
+	if len(rows) != 2 || rows[0][0].ID != "opus" || rows[1][0].ID != "v480" {
+		t.Fatalf("rows = %+v", rows)
+	}
+}
+
+func TestPresetValidation(t *testing.T) {
+	t.Parallel()
+	ok := Preset{ID: "a", Label: "A", Format: "b", Send: SendVideo}
+	cases := map[string][]Preset{
+		"no presets":     nil,
+		"id must match":  {{ID: "bad;id", Label: "A", Format: "b", Send: SendVideo}},
+		"reserved":       {{ID: "live5", Label: "A", Format: "b", Send: SendVideo}},
+		"empty label":    {{ID: "a", Format: "b", Send: SendVideo}},
+		"empty format":   {{ID: "a", Label: "A", Send: SendVideo}},
+		"send must be":   {{ID: "a", Label: "A", Format: "b", Send: "photo"}},
+		"duplicate id":   {ok, ok},
+		"duplicate code": {ok, {ID: "b", Code: "a", Label: "B", Format: "b", Send: SendVideo}},
+		"unknown step":   {{ID: "a", Label: "A", Format: "b", Send: SendVideo, PostProcess: []string{"sharpen"}}},
+	}
+	for want, list := range cases {
+		if _, err := NewPresets(list); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("NewPresets(%s) err = %v; want %q", want, err, want)
+		}
+	}
+	if _, err := NewPresets(builtinPresets); err != nil {
+		t.Fatalf("builtin presets invalid: %v", err)
+	}
+}
+
+func TestPresetsAvailable(t *testing.T) {
+	t.Parallel()
+	if _, ok := (&Config{}).PresetRegistry().Get("video2160"); ok {
+		t.Fatal("local_only preset offered without local Bot API")
+	}
+	if _, ok := (&Config{LocalBotAPI: true}).PresetRegistry().Get("video2160"); !ok {
+		t.Fatal("local_only preset missing with local Bot API")
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// допустимые символы secret_token по документации Bot API
+var webhookSecretRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// checkWebhook — проверка WEBHOOK_*; путь по умолчанию выводится из токена, чтобы его нельзя было угадать
+func (c *Config) checkWebhook() error {
+	if c.WebhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.WebhookURL)
+	// путь задаётся отдельно (WEBHOOK_PATH), и сервер слушает ровно его
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.Path != "" {
+		return fmt.Errorf("invalid WEBHOOK_URL %q (https://host[:port])", c.WebhookURL)
+	}
+	if c.WebhookSecret != "" && !webhookSecretRe.MatchString(c.WebhookSecret) {
+		return errors.New("invalid WEBHOOK_SECRET (1-256 of A-Z a-z 0-9 _ -)")
+	}
+	if (c.WebhookCert == "") != (c.WebhookKey == "") {
+		return errors.New("WEBHOOK_CERT and WEBHOOK_KEY must be set together")
+	}
+	if c.WebhookPath == "" {
+		sum := sha256.Sum256([]byte(c.TelegramToken))
+		c.WebhookPath = "/tg/" + hex.EncodeToString(sum[:12])
+	}
+	if !strings.HasPrefix(c.WebhookPath, "/") {
+		c.WebhookPath = "/" + c.WebhookPath
+	}
+	return nil
+}
+
+// WebhookEndpoint — полный адрес для setWebhook
+func (c *Config) WebhookEndpoint() string { return c.WebhookURL + c.WebhookPath }
@@ -0,0 +1,209 @@
+package downloader
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"youtube-bot-simple/internal/config"
+)
+
+// Budget — общий лимит скорости, который делится поровну между идущими загрузками.
+// --limit-rate у процесса yt-dlp фиксирован, поэтому при заметном изменении доли
+// задача получает сигнал и перезапускает yt-dlp с новой скоростью (докачка из .part).
+// Запись трансляции перезапускать нельзя: её доля фиксируется при выдаче (не больше
+// половины свободного, чтобы обычным загрузкам было что делить), а остальные задачи
+// делят то, что осталось
+
+type Budget struct {
+	mu     sync.Mutex
+	cfg    *config.Config
+	leases map[*Lease]struct{}
+}
+
+// Lease — доля канала одной задачи
+type Lease struct {
+	b       *Budget
+	rate    int64 // байт/с, 0 — без лимита
+	fixed   bool  // доля не меняется до Release
+	changed chan struct{}
+}
+
+func NewBudget(cfg *config.Config) *Budget {
+	return &Budget{cfg: cfg, leases: make(map[*Lease]struct{})}
+}
+
+// Acquire — выдать долю новой задаче и пересчитать доли остальных
+func (b *Budget) Acquire() *Lease { return b.acquire(false) }
+
+// AcquireFixed — доля, которая не меняется до Release (запись трансляции)
+func (b *Budget) AcquireFixed() *Lease { return b.acquire(true) }
+
+func (b *Budget) acquire(fixed bool) *Lease {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := &Lease{b: b, rate: -1, fixed: fixed, changed: make(chan struct{})}
+	b.leases[l] = struct{}{}
+	b.rebalanceLocked()
+	return l
+}
+
+// Release — вернуть долю; оставшиеся задачи получают больше
+func (l *Lease) Release() {
+	l.b.mu.Lock()
+	defer l.b.mu.Unlock()
+	delete(l.b.leases, l)
+	l.b.rebalanceLocked()
+}
+
+// Rate — текущая доля, байт/с (0 — без лимита)
+func (l *Lease) Rate() int64 {
+	l.b.mu.Lock()
+	defer l.b.mu.Unlock()
+	return l.rate
+}
+
+// Changed — закрывается, когда доля задачи заметно изменилась
+func (l *Lease) Changed() <-chan struct{} {
+	l.b.mu.Lock()
+	defer l.b.mu.Unlock()
+	return l.changed
+}
+
+// Start — пересчёт долей при смене окна расписания
+func (b *Budget) Start(ctx context.Context) {
+	if len(b.cfg.BandwidthSchedule) == 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				b.mu.Lock()
+				b.rebalanceLocked()
+				b.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Limit — общий лимит сейчас и число задач, между которыми он делится
+func (b *Budget) Limit() (total int64, active int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cfg.BandwidthAt(time.Now()), len(b.leases)
+}
+
+// rebalanceLocked — поровну между задачами без фиксированной доли, так что сумма
+// долей не больше лимита; доля не бывает меньше 1 байт/с, иначе 0 снял бы лимит
+func (b *Budget) rebalanceLocked() {
+	if len(b.leases) == 0 {
+		return
+	}
+	total := b.cfg.BandwidthAt(time.Now())
+	var fixed, open int64
+	for l := range b.leases {
+		switch {
+		case !l.fixed || l.rate == -1:
+			open++
+		case l.rate == 0 && total > 0:
+			// запись выдана без лимита, а расписание его ввело: она может занять весь канал
+			fixed += total
+		default:
+			fixed += l.rate
+		}
+	}
+	var share, fixedShare int64
+	if total > 0 && open > 0 {
+		share = (total - fixed) / open
+		if share < 1 {
+			share = 1
+		}
+		// фиксированная доля навсегда: не больше половины свободного
+		fixedShare = share
+		if half := (total - fixed) / 2; fixedShare > half {
+			fixedShare = half
+		}
+		if fixedShare < 1 {
+			fixedShare = 1
+		}
+	}
+	for l := range b.leases {
+		switch {
+		case l.rate == -1 && l.fixed:
+			l.rate = fixedShare
+		case l.rate == -1:
+			// новая задача ещё не запущена — просто назначаем долю
+			l.rate = share
+		case l.fixed:
+		case significant(l.rate, share):
+			l.rate = share
+			close(l.changed)
+			l.changed = make(chan struct{})
+		}
+	}
+}
+
+// significant — изменение доли стоит перезапуска yt-dlp (больше 20% или снятие/введение лимита)
+func significant(old, next int64) bool {
+	if old == next {
+		return false
+	}
+	if old == 0 || next == 0 {
+		return true
+	}
+	diff := old - next
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*5 > old
+}
+
+// runFixed — запуск с постоянной долей канала: запись трансляции не перезапускается,
+// но её доля вычитается из лимита остальных загрузок
+func (r *Runner) runFixed(ctx context.Context, args []string, jobID, url string, timeout time.Duration) (stdout, stderr, via string, err error) {
+	lease := r.budget.AcquireFixed()
+	defer lease.Release()
+	if rate := lease.Rate(); rate > 0 {
+		args = append([]string{"--limit-rate", strconv.FormatInt(rate, 10)}, args...)
+	}
+	return r.runWithProxies(ctx, r.bin(), args, jobID, url, timeout)
+}
+
+// runThrottled — запуск yt-dlp в пределах доли канала; при перераспределении
+// процесс останавливается и запускается заново с новой скоростью
+func (r *Runner) runThrottled(ctx context.Context, args []string, jobID, url string, timeout time.Duration) (stdout, stderr, via string, err error) {
+	lease := r.budget.Acquire()
+	defer lease.Release()
+
+	for {
+		rate := lease.Rate()
+		runArgs := args
+		if rate > 0 {
+			runArgs = append([]string{"--limit-rate", strconv.FormatInt(rate, 10)}, args...)
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		changed := lease.Changed()
+		go func() {
+			select {
+			case <-changed:
+				cancel()
+			case <-runCtx.Done():
+			}
+		}()
+		stdout, stderr, via, err = r.runWithProxies(runCtx, r.bin(), runArgs, jobID, url, timeout)
+		rebalanced := err != nil && runCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if !rebalanced {
+			return stdout, stderr, via, err
+		}
+		log.Printf("[downloader] bandwidth share changed (%d → %d B/s), restarting with --continue", rate, lease.Rate())
+	}
+}
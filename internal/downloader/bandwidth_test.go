@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"testing"
+
+	"youtube-bot-simple/internal/config"
+)
+
+func TestBudgetSplitsAmongActiveJobs(t *testing.T) {
+	t.Parallel()
+	b := NewBudget(&config.Config{BandwidthLimit: 1024 * 1024})
+
+	l1 := b.Acquire()
+	if got := l1.Rate(); got != 1024*1024 {
+		t.Fatalf("single job rate = %d; want full budget", got)
+	}
+	ch1 := l1.Changed()
+
+	l2 := b.Acquire()
+	if l1.Rate() != 512*1024 || l2.Rate() != 512*1024 {
+		t.Fatalf("rates = %d/%d; want half each", l1.Rate(), l2.Rate())
+	}
+	select {
+	case <-ch1:
+	default:
+		t.Fatalf("first job was not notified about its smaller share")
+	}
+
+	ch2 := l2.Changed()
+	l1.Release()
+	if l2.Rate() != 1024*1024 {
+		t.Fatalf("rate after release = %d; want full budget", l2.Rate())
+	}
+	select {
+	case <-ch2:
+	default:
+		t.Fatalf("remaining job was not notified about its larger share")
+	}
+	l2.Release()
+}
+
+func TestBudgetFixedShareCountsTowardLimit(t *testing.T) {
+	t.Parallel()
+	b := NewBudget(&config.Config{BandwidthLimit: 900 * 1024})
+
+	flex := b.Acquire()
+	live := b.AcquireFixed()
+	if live.Rate() != 450*1024 || flex.Rate() != 450*1024 {
+		t.Fatalf("rates = live %d / flex %d; want half each", live.Rate(), flex.Rate())
+	}
+	liveCh := live.Changed()
+
+	// новые задачи делят остаток, запись трансляции не перезапускается
+	flex2 := b.Acquire()
+	if live.Rate() != 450*1024 || flex.Rate() != 225*1024 || flex2.Rate() != 225*1024 {
+		t.Fatalf("rates = live %d / flex %d / flex2 %d", live.Rate(), flex.Rate(), flex2.Rate())
+	}
+	select {
+	case <-liveCh:
+		t.Fatalf("fixed lease must not be restarted")
+	default:
+	}
+	live.Release()
+	if flex.Rate() != 450*1024 || flex2.Rate() != 450*1024 {
+		t.Fatalf("rates after live ended = %d/%d", flex.Rate(), flex2.Rate())
+	}
+}
+
+func TestBudgetFixedShareLeavesRoomForLaterJobs(t *testing.T) {
+	t.Parallel()
+	b := NewBudget(&config.Config{BandwidthLimit: 900 * 1024})
+
+	// запись на простаивающем боте не забирает весь канал: следующей загрузке остаётся половина
+	live := b.AcquireFixed()
+	if live.Rate() != 450*1024 {
+		t.Fatalf("live rate on idle bot = %d; want half the limit", live.Rate())
+	}
+	flex := b.Acquire()
+	if flex.Rate() != 450*1024 {
+		t.Fatalf("flex rate after live = %d; want the rest", flex.Rate())
+	}
+	flex.Release()
+	live.Release()
+
+	// запись, начатая без лимита, после введения лимита расписанием занимает его целиком
+	cfg := &config.Config{}
+	b = NewBudget(cfg)
+	live = b.AcquireFixed()
+	if live.Rate() != 0 {
+		t.Fatalf("live rate without limit = %d", live.Rate())
+	}
+	cfg.BandwidthLimit = 900 * 1024
+	flex = b.Acquire()
+	if flex.Rate() != 1 {
+		t.Fatalf("flex rate next to unlimited live = %d; want the minimum", flex.Rate())
+	}
+}
+
+func TestBudgetNeverExceedsLimit(t *testing.T) {
+	t.Parallel()
+	// доля меньше прежнего минимума в 32 КБ/с: сумма всё равно в пределах лимита
+	const total = 64 * 1024
+	b := NewBudget(&config.Config{BandwidthLimit: total})
+	var leases []*Lease
+	for i := 0; i < 5; i++ {
+		leases = append(leases, b.Acquire())
+	}
+	var sum int64
+	for _, l := range leases {
+		if l.Rate() <= 0 {
+			t.Fatalf("rate %d; want a positive limit", l.Rate())
+		}
+		sum += l.Rate()
+	}
+	if sum > total {
+		t.Fatalf("sum of shares %d > limit %d", sum, total)
+	}
+}
+
+func TestBudgetUnlimited(t *testing.T) {
+	t.Parallel()
+	b := NewBudget(&config.Config{})
+	l1, l2 := b.Acquire(), b.Acquire()
+	if l1.Rate() != 0 || l2.Rate() != 0 {
+		t.Fatalf("unlimited budget gave rates %d/%d", l1.Rate(), l2.Rate())
+	}
+	select {
+	case <-l1.Changed():
+		t.Fatalf("unlimited budget must not trigger restarts")
+	default:
+	}
+}
+
+func TestSignificant(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		old, next int64
+		want      bool
+	}{
+		{100, 100, false},
+		{100, 90, false},
+		{100, 50, true},
+		{0, 100, true},
+		{100, 0, true},
+	}
+	for i, tc := range cases {
+		if got := significant(tc.old, tc.next); got != tc.want {
+			t.Fatalf("case %d: significant(%d,%d) = %v", i, tc.old, tc.next, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen — загрузки приостановлены: YouTube ограничивает запросы
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Breaker — общий для всех задач предохранитель: после серии 429/«не робот»
+// за окно window прекращает обращения к YouTube на cooldown
+
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	hits      []time.Time
+	openUntil time.Time
+	onOpen    func(until time.Time, kind ErrorKind)
+}
+
+func NewBreaker(threshold int, window, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	if cooldown <= 0 {
+		cooldown = 15 * time.Minute
+	}
+	return &Breaker{threshold: threshold, window: window, cooldown: cooldown}
+}
+
+// OnOpen — колбэк при срабатывании (пауза очереди, уведомление админов);
+// вызывается вне блокировки
+func (b *Breaker) OnOpen(fn func(until time.Time, kind ErrorKind)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onOpen = fn
+}
+
+// OpenUntil — время, до которого загрузки приостановлены; ok=false если закрыт
+func (b *Breaker) OpenUntil() (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Now().Before(b.openUntil) {
+		return b.openUntil, true
+	}
+	return time.Time{}, false
+}
+
+// Report — учесть результат задачи; возвращает время окончания паузы,
+// если предохранитель сработал (или уже открыт) из-за этой ошибки
+func (b *Breaker) Report(kind ErrorKind) (time.Time, bool) {
+	if kind != KindRateLimited && kind != KindBotCheck {
+		return time.Time{}, false
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		until := b.openUntil
+		b.mu.Unlock()
+		return until, true
+	}
+	// только попадания внутри окна
+	kept := b.hits[:0]
+	for _, t := range b.hits {
+		if now.Sub(t) <= b.window {
+			kept = append(kept, t)
+		}
+	}
+	b.hits = append(kept, now)
+	if len(b.hits) < b.threshold {
+		b.mu.Unlock()
+		return time.Time{}, false
+	}
+	b.hits = nil
+	b.openUntil = now.Add(b.cooldown)
+	until, fn := b.openUntil, b.onOpen
+	b.mu.Unlock()
+
+	if fn != nil {
+		fn(until, kind)
+	}
+	return until, true
+}
+
+// Success — успешная загрузка сбрасывает накопленные попадания
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hits = nil
+}
@@ -0,0 +1,45 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	t.Parallel()
+	br := NewBreaker(2, time.Minute, time.Hour)
+	var opened int
+	br.OnOpen(func(until time.Time, kind ErrorKind) { opened++ })
+
+	// прочие ошибки не учитываются
+	if _, open := br.Report(KindPrivate); open {
+		t.Fatalf("private error must not open the breaker")
+	}
+	if _, open := br.Report(KindRateLimited); open {
+		t.Fatalf("first 429 must not open the breaker")
+	}
+	until, open := br.Report(KindBotCheck)
+	if !open || time.Until(until) < 59*time.Minute {
+		t.Fatalf("second hit: open=%v until=%v; want open for ~1h", open, until)
+	}
+	if opened != 1 {
+		t.Fatalf("OnOpen called %d times; want 1", opened)
+	}
+	if _, open := br.OpenUntil(); !open {
+		t.Fatalf("OpenUntil reports closed while cooling down")
+	}
+	// повторные ошибки во время паузы не вызывают колбэк снова
+	if _, open := br.Report(KindRateLimited); !open || opened != 1 {
+		t.Fatalf("report while open: open=%v opened=%d", open, opened)
+	}
+}
+
+func TestBreakerSuccessResetsHits(t *testing.T) {
+	t.Parallel()
+	br := NewBreaker(2, time.Minute, time.Hour)
+	br.Report(KindRateLimited)
+	br.Success()
+	if _, open := br.Report(KindRateLimited); open {
+		t.Fatalf("success should reset accumulated hits")
+	}
+}
@@ -0,0 +1,132 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrorKind — класс ошибки yt-dlp, по которому бот выбирает текст для пользователя
+type ErrorKind string
+
+const (
+	KindUnknown        ErrorKind = "unknown"
+	KindPrivate        ErrorKind = "private"
+	KindRemoved        ErrorKind = "removed"
+	KindGeoBlocked     ErrorKind = "geo_blocked"
+	KindAgeRestricted  ErrorKind = "age_restricted"
+	KindMembersOnly    ErrorKind = "members_only"
+	KindLiveNotStarted ErrorKind = "live_not_started"
+	KindRateLimited    ErrorKind = "rate_limited"
+	KindBotCheck       ErrorKind = "bot_check"
+	KindNetwork        ErrorKind = "network"
+	KindDiskFull       ErrorKind = "disk_full"
+	KindTimeout        ErrorKind = "timeout"
+	KindLive           ErrorKind = "live"
+)
+
+// Error — типизированная ошибка загрузки; Stderr — сырой вывод только для логов и админов;
+// RetryAt — когда задачу имеет смысл повторить (предохранитель открыт)
+type Error struct {
+	Kind    ErrorKind
+	Stderr  string
+	Err     error
+	RetryAt time.Time
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("yt-dlp failed (%s): %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// KindOf — класс ошибки; для нетипизированных ошибок — KindUnknown
+func KindOf(err error) ErrorKind {
+	var de *Error
+	if errors.As(err, &de) {
+		return de.Kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	return KindUnknown
+}
+
+// StderrOf — сырой stderr yt-dlp из ошибки, если есть
+func StderrOf(err error) string {
+	var de *Error
+	if errors.As(err, &de) {
+		return de.Stderr
+	}
+	return ""
+}
+
+// rateLimitBackoff — пауза после 429/«не робот», пока предохранитель ещё не сработал
+const rateLimitBackoff = 2 * time.Minute
+
+// RetryAt — время повтора для отложенной (а не проваленной) задачи: конец паузы
+// предохранителя, а для 429/«не робот» до его срабатывания — короткая пауза
+func RetryAt(err error) (time.Time, bool) {
+	var de *Error
+	if !errors.As(err, &de) {
+		return time.Time{}, false
+	}
+	if !de.RetryAt.IsZero() {
+		return de.RetryAt, true
+	}
+	if de.Kind == KindRateLimited || de.Kind == KindBotCheck {
+		return time.Now().Add(rateLimitBackoff), true
+	}
+	return time.Time{}, false
+}
+
+// порядок важен: более специфичные шаблоны раньше общих
+// («Video unavailable. This video is private» — это private, а не removed)
+var classifyRules = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{KindBotCheck, []string{"confirm you're not a bot", "confirm you’re not a bot", "not a bot"}},
+	{KindRateLimited, []string{"http error 429", "too many requests", "rate-limited", "rate limited"}},
+	{KindDiskFull, []string{"no space left on device", "disk quota exceeded"}},
+	{KindPrivate, []string{"private video", "video is private"}},
+	{KindMembersOnly, []string{"members-only", "members only", "join this channel to get access", "available to this channel's members"}},
+	{KindAgeRestricted, []string{"confirm your age", "age-restricted", "age restricted", "inappropriate for some users"}},
+	{KindGeoBlocked, []string{"not available in your country", "not made this video available in your country", "geo restricted", "geo-restricted", "blocked it in your country"}},
+	{KindLiveNotStarted, []string{"live event will begin", "premieres in", "premiere will begin", "this live event will", "is upcoming"}},
+	{KindRemoved, []string{"video unavailable", "has been removed", "no longer available", "account associated with this video has been terminated", "video does not exist"}},
+}
+
+// Classify — разбор stderr yt-dlp и ошибки процесса в класс ошибки
+func Classify(stderr string, err error) ErrorKind {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	ls := strings.ToLower(stderr)
+	for _, r := range classifyRules {
+		for _, p := range r.patterns {
+			if strings.Contains(ls, p) {
+				return r.kind
+			}
+		}
+	}
+	if looksLikeDNS(stderr) || (err != nil && looksLikeDNS(err.Error())) ||
+		strings.Contains(ls, "unable to download webpage") || strings.Contains(ls, "connection reset") {
+		return KindNetwork
+	}
+	return KindUnknown
+}
+
+// rejectedByFilter — yt-dlp остановился на --match-filter: с --break-match-filters это код 101
+func rejectedByFilter(err error) bool {
+	var ee *exec.ExitError
+	return errors.As(err, &ee) && ee.ExitCode() == 101
+}
+
+// networkKind — ошибки, которые зависят от выходного IP (повод сменить прокси)
+func networkKind(k ErrorKind) bool {
+	return k == KindNetwork || k == KindRateLimited || k == KindBotCheck
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/queue"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+	exit := errors.New("exit status 1")
+	cases := []struct {
+		stderr string
+		err    error
+		want   ErrorKind
+	}{
+		{"ERROR: [youtube] abc: Video unavailable. This video is private", exit, KindPrivate},
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access to this video", exit, KindPrivate},
+		{"ERROR: [youtube] abc: Video unavailable. This video has been removed by the uploader", exit, KindRemoved},
+		{"ERROR: [youtube] abc: Video unavailable", exit, KindRemoved},
+		{"ERROR: [youtube] abc: Video unavailable. The uploader has not made this video available in your country", exit, KindGeoBlocked},
+		{"ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users.", exit, KindAgeRestricted},
+		{"ERROR: [youtube] abc: Join this channel to get access to members-only content like this video", exit, KindMembersOnly},
+		{"ERROR: [youtube] abc: This live event will begin in 3 hours.", exit, KindLiveNotStarted},
+		{"ERROR: [youtube] abc: Premieres in 20 minutes", exit, KindLiveNotStarted},
+		{"ERROR: unable to download video data: HTTP Error 429: Too Many Requests", exit, KindRateLimited},
+		{"ERROR: [youtube] abc: Sign in to confirm you’re not a bot. Use --cookies-from-browser", exit, KindBotCheck},
+		{"ERROR: Unable to download webpage: <urlopen error [Errno -3] Temporary failure in name resolution>", exit, KindNetwork},
+		{"ERROR: unable to write data: [Errno 28] No space left on device", exit, KindDiskFull},
+		{"", fmt.Errorf("%w after 10m0s: signal: killed", context.DeadlineExceeded), KindTimeout},
+		{"ERROR: something odd", exit, KindUnknown},
+	}
+	for i, tc := range cases {
+		if got := Classify(tc.stderr, tc.err); got != tc.want {
+			t.Fatalf("case %d: Classify(%q) = %s; want %s", i, tc.stderr, got, tc.want)
+		}
+	}
+}
+
+func TestKindOfAndStderrOf(t *testing.T) {
+	t.Parallel()
+	err := fmt.Errorf("job: %w", &Error{Kind: KindPrivate, Stderr: "raw", Err: errors.New("exit status 1")})
+	if KindOf(err) != KindPrivate || StderrOf(err) != "raw" {
+		t.Fatalf("KindOf/StderrOf = %s/%q", KindOf(err), StderrOf(err))
+	}
+	if KindOf(errors.New("x")) != KindUnknown || StderrOf(errors.New("x")) != "" {
+		t.Fatalf("untyped error should be unknown without stderr")
+	}
+
+	// 429 и «не робот» откладываются и до срабатывания предохранителя, прочие ошибки — нет
+	if at, ok := RetryAt(&Error{Kind: KindBotCheck, Err: errors.New("exit status 1")}); !ok || time.Until(at) <= 0 || time.Until(at) > rateLimitBackoff {
+		t.Fatalf("bot check RetryAt = %v, %v; want short backoff", at, ok)
+	}
+	until := time.Now().Add(time.Hour)
+	if at, ok := RetryAt(&Error{Kind: KindRateLimited, RetryAt: until}); !ok || !at.Equal(until) {
+		t.Fatalf("breaker RetryAt = %v, %v", at, ok)
+	}
+	if _, ok := RetryAt(err); ok {
+		t.Fatal("private video must not be retried")
+	}
+}
+
+func TestDownloadRejectsLiveForRegularJob(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	// поддельный yt-dlp: ролик — идущая трансляция, фильтр !is_live её отклоняет
+	ytdlp := writeTool(t, dir, "yt-dlp", `#!/bin/sh
+case "$*" in *"--match-filter !is_live --break-match-filters"*) exit 101;; esac
+exit 1
+`)
+	r := NewRunner(&config.Config{DownloadDir: dir, YtDlpPath: ytdlp, CmdTimeoutSec: 5})
+	_, err := r.Download(context.Background(), DownloadRequest{Job: queue.Job{ID: "j", URL: "https://youtu.be/abc", Variant: queue.Variant("video720")}})
+	if KindOf(err) != KindLive {
+		t.Fatalf("err = %v; want live kind", err)
+	}
+}
@@ -0,0 +1,30 @@
+package downloader
+
+import (
+	"fmt"
+	"time"
+
+	"youtube-bot-simple/internal/queue"
+)
+
+// запас сверх длительности записи на запуск yt-dlp и финализацию файла
+const liveSlack = 3 * time.Minute
+
+// liveArgs — аргументы записи трансляции и жёсткий таймаут процесса
+func (r *Runner) liveArgs(args []string, job queue.Job) ([]string, time.Duration) {
+	minutes := job.LiveMinutes
+	if max := r.cfg.LiveMaxMinutes; max > 0 && minutes > max {
+		minutes = max
+	}
+	sec := minutes * 60
+	if job.LiveFromStart {
+		// с начала эфира: берём только первые sec секунд
+		args = append(args, "--live-from-start", "--download-sections", fmt.Sprintf("*0-%d", sec),
+			"-f", "bv*[height<=720]+ba/b[height<=720]/b", "--merge-output-format", "mp4")
+	} else {
+		// с текущего момента: ffmpeg сам остановит запись и корректно закроет mp4
+		args = append(args, "-f", "b[height<=720]/b", "--downloader", "ffmpeg",
+			"--downloader-args", fmt.Sprintf("ffmpeg_o:-t %d", sec), "--merge-output-format", "mp4")
+	}
+	return args, time.Duration(sec)*time.Second + liveSlack
+}
@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MediaInfo — параметры готового файла по данным ffprobe
+type MediaInfo struct {
+	Width      int
+	Height     int
+	Duration   float64 // секунды
+	VideoCodec string  // h264, vp9, av1…; пусто — видеодорожки нет
+	AudioCodec string
+	HasCover   bool // обложка (attached_pic) внутри файла
+}
+
+// сторона превью: Telegram принимает JPEG не больше 320×320
+const thumbSide = 320
+
+// inspect — ffprobe по файлу; обложка (attached_pic) видеодорожкой не считается
+func (r *Runner) inspect(ctx context.Context, path string) (MediaInfo, error) {
+	ctxTO, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctxTO, r.ffprobeBin(), "-v", "error", "-print_format", "json",
+		"-show_streams", "-show_format", path).Output()
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("ffprobe: %w", err)
+	}
+
+	var raw struct {
+		Streams []struct {
+			CodecType   string `json:"codec_type"`
+			CodecName   string `json:"codec_name"`
+			Width       int    `json:"width"`
+			Height      int    `json:"height"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return MediaInfo{}, fmt.Errorf("ffprobe: decode: %w", err)
+	}
+	var mi MediaInfo
+	for _, s := range raw.Streams {
+		switch {
+		case s.CodecType == "video" && s.Disposition.AttachedPic == 1:
+			mi.HasCover = true
+		case s.CodecType == "video" && mi.VideoCodec == "":
+			mi.VideoCodec, mi.Width, mi.Height = s.CodecName, s.Width, s.Height
+		case s.CodecType == "audio" && mi.AudioCodec == "":
+			mi.AudioCodec = s.CodecName
+		}
+	}
+	mi.Duration, _ = strconv.ParseFloat(raw.Format.Duration, 64)
+	return mi, nil
+}
+
+// thumbnail — превью рядом с файлом: кадр видео или обложка аудио; пусто — не получилось
+func (r *Runner) thumbnail(ctx context.Context, path string, mi MediaInfo) string {
+	if mi.VideoCodec == "" && !mi.HasCover {
+		return ""
+	}
+	thumb := strings.TrimSuffix(path, filepath.Ext(path)) + ".thumb.jpg"
+	args := []string{"-hide_banner", "-nostdin", "-y"}
+	if mi.VideoCodec != "" && mi.Duration > 2 {
+		// первый кадр часто чёрный — берём секунду от начала
+		args = append(args, "-ss", "1")
+	}
+	scale := fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease", thumbSide, thumbSide)
+	args = append(args, "-i", path, "-map", "0:v:0", "-frames:v", "1", "-vf", scale, "-q:v", "5", thumb)
+
+	ctxTO, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctxTO, r.ffmpegBin(), args...).CombinedOutput(); err != nil {
+		log.Printf("[downloader] thumbnail failed: %v: %s", err, truncate(lastLine(string(out)), 200))
+		return ""
+	}
+	return thumb
+}
+
+// ffprobeBin — ffprobe, найденный при проверке окружения, рядом с ffmpeg или из $PATH
+func (r *Runner) ffprobeBin() string {
+	if rep := r.Report(); rep != nil && rep.FFprobe.Found() {
+		return rep.FFprobe.Path
+	}
+	if p := r.cfg.FFmpegPath; p != "" {
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			return filepath.Join(p, "ffprobe")
+		}
+		return filepath.Join(filepath.Dir(p), "ffprobe")
+	}
+	return "ffprobe"
+}
@@ -0,0 +1,392 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/queue"
+)
+
+// KindPostProcess — файл скачан, но обязательный шаг постобработки не прошёл
+const KindPostProcess ErrorKind = "postprocess"
+
+// errSkip — шаг неприменим к файлу (нет обложки, контейнер уже нужный и т.п.)
+var errSkip = errors.New("step not applicable")
+
+// состояния шага в StepEvent
+const (
+	StepStart    = "start"
+	StepProgress = "progress"
+	StepDone     = "done"
+	StepSkip     = "skip"
+	StepFail     = "fail"
+)
+
+// StepEvent — ход одного шага постобработки
+type StepEvent struct {
+	Step    string
+	Index   int // с 1
+	Total   int
+	State   string
+	Percent int
+	Elapsed time.Duration
+	Err     error
+}
+
+// StepError — сбой шага постобработки; Stderr — хвост вывода ffmpeg
+type StepError struct {
+	Step   string
+	Stderr string
+	Err    error
+}
+
+func (e *StepError) Error() string { return fmt.Sprintf("postprocess step %s: %v", e.Step, e.Err) }
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// media — файл в рабочей директории задачи и всё, что известно о нём шагам
+type media struct {
+	path   string
+	audio  bool   // аудио-вариант: целевой формат — format
+	format string // mp3 или m4a; пусто — mp3
+	thumb  string // обложка от --write-thumbnail
+	meta   mediaMeta
+	info   MediaInfo // ffprobe; заполняется перед шагами, которым нужны кодеки
+}
+
+// ffmpegStep — аргументы ffmpeg для шага: доп. входы, параметры вывода и расширение результата
+type ffmpegStep func(m *media) (inputs, opts []string, ext string, err error)
+
+// обязательные шаги: без них файл не соответствует варианту; остальные — по возможности
+// (compat тоже: без перекодирования исходный файл уходит документом)
+var postSteps = map[string]struct {
+	run      ffmpegStep
+	required bool
+}{
+	"remux":       {stepRemux, true},
+	"reencode":    {stepReencode, true},
+	"loudnorm":    {stepLoudnorm, false},
+	"trimsilence": {stepTrimSilence, false},
+	"thumbnail":   {stepThumbnail, false},
+	"metadata":    {stepMetadata, false},
+	"compat":      {stepCompat, false},
+}
+
+// postArgs — что yt-dlp должен положить рядом с файлом для шагов постобработки
+func postArgs(steps []config.PostStep) []string {
+	if len(steps) == 0 {
+		return nil
+	}
+	for _, s := range steps {
+		if s.Name == "thumbnail" {
+			return []string{"--write-thumbnail", "--convert-thumbnails", "jpg"}
+		}
+	}
+	return nil
+}
+
+// postProcess — шаги по порядку поверх скачанного файла в рабочей директории;
+// необязательный шаг при сбое пропускается, обязательный — останавливает задачу
+func (r *Runner) postProcess(ctx context.Context, job queue.Job, work, path string, steps []config.PostStep, audio bool, onStep func(StepEvent)) (string, error) {
+	if len(steps) == 0 {
+		return path, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(work, path)
+	}
+	m := &media{path: path, audio: audio, format: job.AudioFormat}
+	m.thumb, m.meta = sidecars(work)
+
+	report := func(ev StepEvent) {
+		ev.Total = len(steps)
+		switch ev.State {
+		case StepFail:
+			log.Printf("[postprocess] job=%s step %d/%d %s failed after %s: %v", job.ID, ev.Index, ev.Total, ev.Step, ev.Elapsed.Round(time.Millisecond), ev.Err)
+		case StepProgress:
+			log.Printf("[postprocess] job=%s step %d/%d %s: %d%%", job.ID, ev.Index, ev.Total, ev.Step, ev.Percent)
+		default:
+			log.Printf("[postprocess] job=%s step %d/%d %s: %s", job.ID, ev.Index, ev.Total, ev.Step, ev.State)
+		}
+		if onStep != nil {
+			onStep(ev)
+		}
+	}
+
+	for i, st := range steps {
+		def, ok := postSteps[st.Name]
+		if !ok {
+			return "", &StepError{Step: st.Name, Err: errors.New("unknown step")}
+		}
+		ev := StepEvent{Step: st.Name, Index: i + 1}
+		if st.Name == "compat" {
+			var perr error
+			if m.info, perr = r.inspect(ctx, m.path); perr != nil {
+				log.Printf("[postprocess] job=%s %v", job.ID, perr)
+			}
+		}
+		inputs, opts, ext, err := def.run(m)
+		if errors.Is(err, errSkip) {
+			ev.State = StepSkip
+			report(ev)
+			continue
+		}
+		timeout := st.Timeout
+		if timeout <= 0 {
+			timeout = r.cfg.PostProcessTimeout
+		}
+
+		ev.State = StepStart
+		report(ev)
+		start := time.Now()
+		var out, stderr string
+		if err == nil {
+			out = strings.TrimSuffix(m.path, filepath.Ext(m.path)) + ".pp." + ext
+			stderr, err = r.runFFmpeg(ctx, m, inputs, opts, out, timeout, func(pct int) {
+				report(StepEvent{Step: st.Name, Index: i + 1, State: StepProgress, Percent: pct})
+			})
+		}
+		ev.Elapsed = time.Since(start)
+		if err != nil {
+			_ = os.Remove(out)
+			ev.State, ev.Err = StepFail, err
+			report(ev)
+			if def.required || ctx.Err() != nil {
+				return "", &StepError{Step: st.Name, Stderr: truncate(stderr, 2000), Err: err}
+			}
+			continue
+		}
+
+		// результат шага заменяет исходный файл
+		next := strings.TrimSuffix(m.path, filepath.Ext(m.path)) + "." + ext
+		if err := os.Rename(out, next); err != nil {
+			return "", &StepError{Step: st.Name, Err: err}
+		}
+		if next != m.path {
+			_ = os.Remove(m.path)
+		}
+		m.path = next
+		ev.State = StepDone
+		report(ev)
+	}
+	return m.path, nil
+}
+
+// runFFmpeg — один запуск ffmpeg с таймаутом и разбором -progress
+func (r *Runner) runFFmpeg(ctx context.Context, m *media, inputs, opts []string, out string, timeout time.Duration, progress func(int)) (string, error) {
+	ctxTO, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-nostdin", "-y", "-i", m.path}
+	for _, in := range inputs {
+		args = append(args, "-i", in)
+	}
+	args = append(args, opts...)
+	args = append(args, "-progress", "pipe:1", "-nostats", out)
+
+	cmd := exec.CommandContext(ctxTO, r.ffmpegBin(), args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	readProgress(stdout, m.meta.Duration, progress)
+	err = cmd.Wait()
+	if err != nil && ctxTO.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("timeout after %s: %w", timeout, context.DeadlineExceeded)
+	}
+	return stderr.String(), err
+}
+
+// readProgress — out_time_us из -progress в проценты; сообщаем каждые 25%
+func readProgress(rd io.Reader, duration float64, progress func(int)) {
+	sc := bufio.NewScanner(rd)
+	last := 0
+	for sc.Scan() {
+		v, ok := strings.CutPrefix(sc.Text(), "out_time_us=")
+		if !ok || duration <= 0 {
+			continue
+		}
+		us, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		pct := int(float64(us) / 1e6 / duration * 100)
+		if pct >= last+25 && pct < 100 {
+			last = pct - pct%25
+			progress(last)
+		}
+	}
+}
+
+// ffmpegBin — ffmpeg из FFMPEG_PATH (файл или директория) или из $PATH
+func (r *Runner) ffmpegBin() string {
+	p := r.cfg.FFmpegPath
+	if p == "" {
+		return "ffmpeg"
+	}
+	if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+		return filepath.Join(p, "ffmpeg")
+	}
+	return p
+}
+
+// sidecars — обложка и info.json, которые yt-dlp положил в рабочую директорию
+func sidecars(work string) (thumb string, meta mediaMeta) {
+	if m, _ := filepath.Glob(filepath.Join(work, "*.jpg")); len(m) > 0 {
+		thumb = m[0]
+	}
+	if m, _ := filepath.Glob(filepath.Join(work, "*.info.json")); len(m) > 0 {
+		if b, err := os.ReadFile(m[0]); err == nil {
+			_ = json.Unmarshal(b, &meta)
+		}
+	}
+	return thumb, meta
+}
+
+func extOf(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// audioCodec — кодек аудио под контейнер (для шагов с фильтрами)
+func audioCodec(ext string) []string {
+	switch ext {
+	case "mp3":
+		return []string{"-c:a", "libmp3lame", "-q:a", "2"}
+	case "webm", "ogg", "opus":
+		return []string{"-c:a", "libopus", "-b:a", "128k"}
+	default:
+		return []string{"-c:a", "aac", "-b:a", "192k"}
+	}
+}
+
+// stepRemux — перепаковка без перекодирования: видео в mp4, аудио без изменений
+func stepRemux(m *media) ([]string, []string, string, error) {
+	if m.audio || extOf(m.path) == "mp4" {
+		return nil, nil, "", errSkip
+	}
+	return nil, []string{"-map", "0", "-c", "copy"}, "mp4", nil
+}
+
+// stepReencode — перекодирование: аудио в mp3 или m4a, видео в H.264/AAC mp4
+func stepReencode(m *media) ([]string, []string, string, error) {
+	if m.audio {
+		target := m.format
+		if target == "" {
+			target = queue.AudioMP3
+		}
+		if extOf(m.path) == target {
+			return nil, nil, "", errSkip
+		}
+		if target == queue.AudioM4A {
+			return nil, []string{"-vn", "-c:a", "aac", "-b:a", "192k"}, "m4a", nil
+		}
+		return nil, []string{"-vn", "-c:a", "libmp3lame", "-q:a", "2"}, "mp3", nil
+	}
+	return nil, h264Args, "mp4", nil
+}
+
+// h264Args — H.264/AAC, который Telegram проигрывает во всех клиентах; moov в начале файла для стриминга
+var h264Args = []string{"-map", "0:v:0", "-map", "0:a?", "-map", "0:s?", "-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+	"-pix_fmt", "yuv420p", "-c:a", "aac", "-b:a", "160k", "-c:s", "mov_text", "-movflags", "+faststart"}
+
+// stepCompat — перекодировать в H.264/AAC, только если кодеки не подходят Telegram (VP9, AV1, Opus)
+func stepCompat(m *media) ([]string, []string, string, error) {
+	if m.audio || m.info.VideoCodec == "" {
+		// аудио или ffprobe не смог прочитать файл — лучше отправить как есть
+		return nil, nil, "", errSkip
+	}
+	if telegramFriendly(m.info) && extOf(m.path) == "mp4" {
+		return nil, nil, "", errSkip
+	}
+	return nil, h264Args, "mp4", nil
+}
+
+// telegramFriendly — кодеки, которые клиенты Telegram показывают inline с превью
+func telegramFriendly(mi MediaInfo) bool {
+	if mi.VideoCodec != "h264" {
+		return false
+	}
+	switch mi.AudioCodec {
+	case "", "aac", "mp3":
+		return true
+	}
+	return false
+}
+
+// stepLoudnorm — выравнивание громкости по EBU R128; видео не трогаем
+func stepLoudnorm(m *media) ([]string, []string, string, error) {
+	ext := extOf(m.path)
+	opts := []string{"-map", "0", "-c:v", "copy", "-af", "loudnorm=I=-16:TP=-1.5:LRA=11"}
+	return nil, append(opts, audioCodec(ext)...), ext, nil
+}
+
+// stepTrimSilence — обрезка тишины в начале и конце; только для аудио, иначе рассинхрон с видео
+func stepTrimSilence(m *media) ([]string, []string, string, error) {
+	if !m.audio {
+		return nil, nil, "", errSkip
+	}
+	ext := extOf(m.path)
+	filter := "silenceremove=start_periods=1:start_threshold=-50dB,areverse,silenceremove=start_periods=1:start_threshold=-50dB,areverse"
+	opts := []string{"-map", "0", "-c:v", "copy", "-af", filter}
+	return nil, append(opts, audioCodec(ext)...), ext, nil
+}
+
+// stepThumbnail — обложка внутрь файла (mp3 и mp4/m4a)
+func stepThumbnail(m *media) ([]string, []string, string, error) {
+	ext := extOf(m.path)
+	if m.thumb == "" {
+		return nil, nil, "", errSkip
+	}
+	switch ext {
+	case "mp3":
+		return []string{m.thumb}, []string{"-map", "0:a", "-map", "1:0", "-c", "copy", "-id3v2_version", "3",
+			"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)"}, ext, nil
+	case "mp4", "m4a":
+		disp := "-disposition:v:1"
+		if m.audio {
+			disp = "-disposition:v:0"
+		}
+		return []string{m.thumb}, []string{"-map", "0", "-map", "1:0", "-c", "copy", disp, "attached_pic"}, ext, nil
+	default:
+		return nil, nil, "", errSkip
+	}
+}
+
+// stepMetadata — название, автор, дата и ссылка из info.json в теги файла
+func stepMetadata(m *media) ([]string, []string, string, error) {
+	if m.meta.Title == "" {
+		return nil, nil, "", errSkip
+	}
+	ext := extOf(m.path)
+	opts := []string{"-map", "0", "-c", "copy", "-metadata", "title=" + m.meta.Title}
+	if m.meta.Uploader != "" {
+		opts = append(opts, "-metadata", "artist="+m.meta.Uploader)
+	}
+	if len(m.meta.UploadDate) >= 4 {
+		opts = append(opts, "-metadata", "date="+m.meta.UploadDate[:4])
+	}
+	if m.meta.WebpageURL != "" {
+		opts = append(opts, "-metadata", "comment="+m.meta.WebpageURL)
+	}
+	if ext == "mp3" {
+		opts = append(opts, "-id3v2_version", "3")
+	}
+	return nil, opts, ext, nil
+}
@@ -0,0 +1,201 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/queue"
+)
+
+// поддельный ffmpeg: копирует вход в выход, падает на loudnorm и libx264
+const fakeFFmpeg = `#!/bin/sh
+in=""; prev=""
+for a in "$@"; do
+  if [ "$prev" = "-i" ] && [ -z "$in" ]; then in="$a"; fi
+  prev="$a"; out="$a"
+done
+case "$*" in *loudnorm*|*libx264*) echo "encoder exploded" >&2; exit 1;; esac
+echo out_time_us=5000000
+cp "$in" "$out"
+`
+
+// поддельный ffprobe: VP9/Opus для файлов с «vp9» в имени, иначе H.264/AAC
+const fakeFFprobe = `#!/bin/sh
+for a in "$@"; do f="$a"; done
+case "$f" in
+  *vp9*) echo '{"streams":[{"codec_type":"video","codec_name":"vp9","width":1080,"height":1920},{"codec_type":"audio","codec_name":"opus"}],"format":{"duration":"12.5"}}';;
+  *) echo '{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720},{"codec_type":"audio","codec_name":"aac"}],"format":{"duration":"12.5"}}';;
+esac
+`
+
+func newPostRunner(t *testing.T) (*Runner, string) {
+	t.Helper()
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(bin, []byte(fakeFFmpeg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(fakeFFprobe), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	work := filepath.Join(dir, "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return NewRunner(&config.Config{DownloadDir: dir, FFmpegPath: bin, PostProcessTimeout: 10 * time.Second}), work
+}
+
+func TestPostProcessPipeline(t *testing.T) {
+	t.Parallel()
+	r, work := newPostRunner(t)
+	src := filepath.Join(work, "abc_video720_T.webm")
+	_ = os.WriteFile(src, []byte("media"), 0o644)
+	_ = os.WriteFile(filepath.Join(work, "abc_video720_T.info.json"), []byte(`{"title":"T","duration":10}`), 0o644)
+
+	// loudnorm необязателен: сбой пропускается, остальные шаги идут дальше
+	steps := []config.PostStep{{Name: "remux"}, {Name: "loudnorm"}, {Name: "thumbnail"}, {Name: "metadata"}}
+	out, err := r.postProcess(context.Background(), queue.Job{ID: "j", Variant: queue.Variant("video720")}, work, src, steps, false, nil)
+	if err != nil {
+		t.Fatalf("postProcess: %v", err)
+	}
+	if filepath.Base(out) != "abc_video720_T.mp4" {
+		t.Fatalf("out = %q; want remuxed mp4", out)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source webm left behind: %v", err)
+	}
+	if m, _ := filepath.Glob(filepath.Join(work, "*.pp.*")); len(m) != 0 {
+		t.Fatalf("intermediate files left: %v", m)
+	}
+}
+
+func TestPostProcessRequiredStepFails(t *testing.T) {
+	t.Parallel()
+	r, work := newPostRunner(t)
+	src := filepath.Join(work, "abc_video720_T.mkv")
+	_ = os.WriteFile(src, []byte("media"), 0o644)
+
+	_, err := r.postProcess(context.Background(), queue.Job{ID: "j", Variant: queue.Variant("video720")}, work, src, []config.PostStep{{Name: "reencode"}}, false, nil)
+	var se *StepError
+	if !errors.As(err, &se) || se.Step != "reencode" || !strings.Contains(se.Stderr, "encoder exploded") {
+		t.Fatalf("err = %v; want StepError for reencode with stderr", err)
+	}
+}
+
+func TestCompatStep(t *testing.T) {
+	t.Parallel()
+	r, work := newPostRunner(t)
+	steps := []config.PostStep{{Name: "compat"}}
+
+	// H.264/AAC в mp4 — перекодировать нечего
+	ok := filepath.Join(work, "a_h264.mp4")
+	_ = os.WriteFile(ok, []byte("media"), 0o644)
+	if out, err := r.postProcess(context.Background(), queue.Job{ID: "j"}, work, ok, steps, false, nil); err != nil || out != ok {
+		t.Fatalf("compat on h264 = %q, %v; want untouched", out, err)
+	}
+
+	// VP9 — перекодирование в libx264 (поддельный ffmpeg на нём падает): задача не падает,
+	// остаётся исходный файл и отметка о сбое шага
+	bad := filepath.Join(work, "b_vp9.mp4")
+	_ = os.WriteFile(bad, []byte("media"), 0o644)
+	res := &DownloadResult{}
+	onStep := func(ev StepEvent) { res.Steps = append(res.Steps, ev) }
+	if out, err := r.postProcess(context.Background(), queue.Job{ID: "j"}, work, bad, steps, false, onStep); err != nil || out != bad || !res.StepFailed("compat") {
+		t.Fatalf("compat on vp9 = %q, %v, steps %+v; want original file and failed step", out, err, res.Steps)
+	}
+
+	mi, err := r.inspect(context.Background(), bad)
+	if err != nil || mi.Width != 1080 || mi.Height != 1920 || mi.Duration != 12.5 || telegramFriendly(mi) {
+		t.Fatalf("inspect = %+v, %v", mi, err)
+	}
+	thumb := r.thumbnail(context.Background(), bad, mi)
+	if thumb == "" {
+		t.Fatalf("thumbnail not generated")
+	}
+	if _, err := os.Stat(thumb); err != nil {
+		t.Fatalf("thumbnail missing: %v", err)
+	}
+}
+
+func TestPostStepsImplemented(t *testing.T) {
+	t.Parallel()
+	for _, name := range config.PostSteps {
+		if _, ok := postSteps[name]; !ok {
+			t.Fatalf("step %q is accepted by config but not implemented", name)
+		}
+	}
+	mp3, ok := config.DefaultPresets().Get("audioMp3")
+	// встроенный mp3 по-прежнему собирает yt-dlp, без шагов ffmpeg
+	if !ok || !mp3.Audio() || len(mp3.Steps) != 0 {
+		t.Fatalf("builtin mp3 preset = %+v", mp3)
+	}
+	if got := strings.Join(presetArgs(mp3, ""), " "); got != "-f ba/b -x --audio-format mp3" {
+		t.Fatalf("mp3 args = %q", got)
+	}
+	if got := strings.Join(presetArgs(mp3, queue.AudioM4A), " "); got != "-f ba/b -x --audio-format m4a" || mp3.ExtraArgs[2] != "mp3" {
+		t.Fatalf("m4a args = %q; preset = %v", got, mp3.ExtraArgs)
+	}
+}
+
+func TestReencodeAudioFormat(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		path, format string
+		wantExt      string
+		skip         bool
+	}{
+		{"a.webm", "", "mp3", false},
+		{"a.mp3", "", "", true},
+		{"a.webm", queue.AudioM4A, "m4a", false},
+		{"a.m4a", queue.AudioM4A, "", true},
+		{"a.m4a", queue.AudioMP3, "mp3", false},
+	}
+	for _, c := range cases {
+		_, opts, ext, err := stepReencode(&media{path: c.path, audio: true, format: c.format})
+		if c.skip {
+			if err != errSkip {
+				t.Errorf("%s→%q: err = %v; want skip", c.path, c.format, err)
+			}
+			continue
+		}
+		if err != nil || ext != c.wantExt || opts[0] != "-vn" {
+			t.Errorf("%s→%q: ext = %q, opts = %v, err = %v", c.path, c.format, ext, opts, err)
+		}
+	}
+	if args := subtitleArgs("en", false); strings.Join(args, " ") != "--write-subs --write-auto-subs --sub-langs en.* --embed-subs" {
+		t.Errorf("subtitleArgs = %v", args)
+	}
+	if args := subtitleArgs("en", true); args != nil {
+		t.Errorf("audio subtitleArgs = %v", args)
+	}
+}
+
+func TestSponsorCut(t *testing.T) {
+	t.Parallel()
+	r, work := newPostRunner(t)
+	src := filepath.Join(work, "abc_video720_T.mp4")
+	_ = os.WriteFile(src, []byte("media"), 0o644)
+
+	// info.json нет — измерить нечем
+	if _, ok := r.sponsorCut(context.Background(), work, src); ok {
+		t.Fatal("cut measured without info.json")
+	}
+
+	// ffprobe отдаёт 12.5 с, до вырезания было 100 с
+	_ = os.WriteFile(filepath.Join(work, "abc_video720_T.info.json"), []byte(`{"title":"T","duration":100}`), 0o644)
+	if cut, ok := r.sponsorCut(context.Background(), work, src); !ok || cut != 87.5 {
+		t.Fatalf("sponsorCut = %v, %v; want 87.5", cut, ok)
+	}
+
+	// разница меньше секунды — ничего не вырезано
+	_ = os.WriteFile(filepath.Join(work, "abc_video720_T.info.json"), []byte(`{"title":"T","duration":12.9}`), 0o644)
+	if cut, ok := r.sponsorCut(context.Background(), work, src); !ok || cut != 0 {
+		t.Fatalf("sponsorCut = %v, %v; want 0", cut, ok)
+	}
+}
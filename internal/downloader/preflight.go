@@ -0,0 +1,323 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/proxy"
+)
+
+// таймаут одного служебного запуска (--version, -encoders и т.п.)
+const preflightTimeout = 30 * time.Second
+
+// сколько последних задач с их прокси показывать в /status
+const statusJobs = 5
+
+// кодеки ffmpeg, от которых зависят варианты загрузки
+var wantEncoders = []string{"libx264", "aac", "libmp3lame", "libopus"}
+
+// возможности, которые деградированный режим отключает по итогам проверки
+const (
+	FeatureFFmpeg = "ffmpeg" // слияние дорожек, постобработка, субтитры, вырезание SponsorBlock
+	FeatureMP3    = "mp3"    // аудио-пресеты: libmp3lame
+	FeatureH264   = "h264"   // перекодирование для совместимости: libx264
+)
+
+// Tool — найденный бинарник и его версия
+type Tool struct {
+	Name    string
+	Path    string
+	Version string
+	Err     string
+}
+
+func (t Tool) Found() bool { return t.Path != "" && t.Err == "" }
+
+// Report — результат проверки окружения при старте
+type Report struct {
+	CheckedAt  time.Time
+	YtDlp      Tool
+	FFmpeg     Tool
+	FFprobe    Tool
+	Extractors int
+	Encoders   map[string]bool
+	Problems   []Problem
+}
+
+// Problem — найденная проблема: ключ каталога сообщений и подстановки, текст — на языке читателя
+type Problem struct {
+	Key  string
+	Args []any
+}
+
+func (p Problem) Text(l i18n.Localizer) string { return l.T(p.Key, p.Args...) }
+
+func (rep *Report) problem(key string, kv ...any) {
+	rep.Problems = append(rep.Problems, Problem{Key: key, Args: kv})
+}
+
+// OK — всё найдено и версии подходят
+func (rep *Report) OK() bool { return len(rep.Problems) == 0 }
+
+// Has — доступна ли возможность; без проверки (nil, PREFLIGHT_MODE=off) — да
+func (rep *Report) Has(feature string) bool {
+	if rep == nil {
+		return true
+	}
+	switch feature {
+	case FeatureFFmpeg:
+		return rep.FFmpeg.Found()
+	case FeatureMP3:
+		return rep.FFmpeg.Found() && rep.Encoders["libmp3lame"]
+	case FeatureH264:
+		return rep.FFmpeg.Found() && rep.Encoders["libx264"]
+	}
+	return true
+}
+
+// Supports — можно ли выполнить пресет: аудио собирается ffmpeg в MP3, шаг compat
+// перекодирует в H.264; видео без ffmpeg качается одним файлом без постобработки
+func (rep *Report) Supports(p config.Preset) bool {
+	if p.Audio() && !rep.Has(FeatureMP3) {
+		return false
+	}
+	if hasStep(p.Steps, "compat") && !rep.Has(FeatureH264) {
+		return false
+	}
+	return true
+}
+
+// Text — отчёт для админов на языке l
+func (rep *Report) Text(l i18n.Localizer) string {
+	var b strings.Builder
+	b.WriteString(l.T("preflight.checked", "time", rep.CheckedAt.Format("02.01 15:04:05")) + "\n")
+	for _, t := range []Tool{rep.YtDlp, rep.FFmpeg, rep.FFprobe} {
+		if t.Found() {
+			fmt.Fprintf(&b, "✅ %s %s (%s)\n", t.Name, t.Version, t.Path)
+		} else {
+			fmt.Fprintf(&b, "❌ %s: %s\n", t.Name, t.Err)
+		}
+	}
+	if rep.Extractors > 0 {
+		b.WriteString(l.T("preflight.extractors", "n", rep.Extractors) + "\n")
+	}
+	if len(rep.Encoders) > 0 {
+		var parts []string
+		for _, name := range wantEncoders {
+			mark := "❌"
+			if rep.Encoders[name] {
+				mark = "✅"
+			}
+			parts = append(parts, mark+name)
+		}
+		b.WriteString(l.T("preflight.encoders", "list", strings.Join(parts, " ")) + "\n")
+	}
+	if rep.OK() {
+		b.WriteString(l.T("preflight.ok"))
+	} else {
+		b.WriteString(l.T("preflight.degraded"))
+		for _, p := range rep.Problems {
+			b.WriteString("\n- " + p.Text(l))
+		}
+	}
+	return b.String()
+}
+
+// Preflight — найти yt-dlp/ffmpeg/ffprobe, записать версии и возможности;
+// найденные абсолютные пути сохраняются в конфиг для дальнейших запусков
+func (r *Runner) Preflight(ctx context.Context) *Report {
+	rep := &Report{CheckedAt: time.Now(), Encoders: make(map[string]bool)}
+
+	rep.YtDlp = resolveTool(ctx, "yt-dlp", r.cfg.YtDlpPath, "--version")
+	if rep.YtDlp.Found() {
+		r.cfg.YtDlpPath = rep.YtDlp.Path
+		if min := r.cfg.YtDlpMinVersion; min != "" && versionOlder(rep.YtDlp.Version, min) {
+			rep.problem("preflight.ytdlp_old", "version", rep.YtDlp.Version, "min", min)
+		}
+		if out, err := runTool(ctx, rep.YtDlp.Path, "--list-extractors"); err == nil {
+			rep.Extractors = countLines(out)
+		}
+	} else {
+		rep.problem("preflight.ytdlp_missing", "error", rep.YtDlp.Err)
+	}
+
+	// FFMPEG_PATH может указывать на бинарник или на директорию с ним
+	ffmpegHint, ffprobeHint := "", ""
+	if p := r.cfg.FFmpegPath; p != "" {
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			ffmpegHint, ffprobeHint = filepath.Join(p, "ffmpeg"), filepath.Join(p, "ffprobe")
+		} else {
+			ffmpegHint, ffprobeHint = p, filepath.Join(filepath.Dir(p), "ffprobe")
+		}
+	}
+	rep.FFmpeg = resolveTool(ctx, "ffmpeg", ffmpegHint, "-version")
+	if rep.FFmpeg.Found() {
+		r.cfg.FFmpegPath = rep.FFmpeg.Path
+		if out, err := runTool(ctx, rep.FFmpeg.Path, "-hide_banner", "-encoders"); err == nil {
+			for _, name := range wantEncoders {
+				rep.Encoders[name] = strings.Contains(out, " "+name+" ")
+			}
+		}
+		for _, name := range wantEncoders {
+			if !rep.Encoders[name] {
+				rep.problem("preflight.no_encoder", "encoder", name)
+			}
+		}
+	} else {
+		rep.problem("preflight.ffmpeg_missing", "error", rep.FFmpeg.Err)
+	}
+
+	rep.FFprobe = resolveTool(ctx, "ffprobe", ffprobeHint, "-version")
+	if !rep.FFprobe.Found() && ffprobeHint != "" {
+		rep.FFprobe = resolveTool(ctx, "ffprobe", "", "-version")
+	}
+	if !rep.FFprobe.Found() {
+		rep.problem("preflight.ffprobe_missing", "error", rep.FFprobe.Err)
+	}
+
+	r.mu.Lock()
+	r.report = rep
+	r.mu.Unlock()
+	return rep
+}
+
+// Report — последний результат Preflight (nil, если проверка не запускалась)
+func (r *Runner) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report
+}
+
+// StatusText — сводка для /status на языке l: окружение, предохранитель, прокси
+func (r *Runner) StatusText(l i18n.Localizer) string {
+	var b strings.Builder
+	if rep := r.Report(); rep != nil {
+		b.WriteString(rep.Text(l))
+	} else {
+		b.WriteString(l.T("status.unchecked"))
+	}
+	if until, open := r.breaker.OpenUntil(); open {
+		b.WriteString("\n" + l.T("status.breaker_open", "time", until.Format("15:04")))
+	} else {
+		b.WriteString("\n" + l.T("status.breaker_closed"))
+	}
+	if total, active := r.budget.Limit(); total > 0 {
+		b.WriteString("\n" + l.N("status.bandwidth", active, "kbps", total/1024))
+	}
+	for _, st := range r.pool.Stats() {
+		b.WriteString("\n" + l.T("status.proxy", "proxy", st.Proxy, "ok", st.Successes, "fail", st.Failures, "score", fmt.Sprintf("%.2f", st.Score)))
+		if time.Now().Before(st.CooldownUntil) {
+			b.WriteString(" " + l.T("status.proxy_paused", "time", st.CooldownUntil.Format("15:04")))
+		}
+	}
+	// какой прокси обслужил последние задачи
+	var jobs []proxy.Usage
+	for _, u := range r.pool.History() {
+		if u.Job != "" {
+			jobs = append(jobs, u)
+		}
+	}
+	if len(jobs) > statusJobs {
+		jobs = jobs[len(jobs)-statusJobs:]
+	}
+	for _, u := range jobs {
+		mark := "✅"
+		if !u.OK {
+			mark = "❌"
+		}
+		via := l.T("status.direct")
+		if u.Proxy != "" {
+			via = proxy.Redact(u.Proxy)
+		}
+		fmt.Fprintf(&b, "\n%s %s %s: %s", mark, u.At.Format("15:04"), u.Job, via)
+	}
+	return b.String()
+}
+
+// resolveTool — явный путь или поиск в $PATH, затем запуск с флагом версии
+func resolveTool(ctx context.Context, name, explicit, versionFlag string) Tool {
+	t := Tool{Name: name}
+	path := explicit
+	if path == "" {
+		path = name
+	}
+	abs, err := exec.LookPath(path)
+	if err != nil {
+		t.Err = err.Error()
+		return t
+	}
+	if a, err := filepath.Abs(abs); err == nil {
+		abs = a
+	}
+	t.Path = abs
+
+	out, err := runTool(ctx, abs, versionFlag)
+	if err != nil {
+		t.Err = fmt.Sprintf("%s %s: %v", name, versionFlag, err)
+		return t
+	}
+	t.Version = parseVersion(out)
+	return t
+}
+
+// versionOlder — v старше min по числовым компонентам через точку (2024.8.6 == 2024.08.06);
+// нечисловой хвост компонента («1-3ubuntu5») отбрасывается
+func versionOlder(v, min string) bool {
+	a, b := strings.Split(v, "."), strings.Split(min, ".")
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := versionPart(a, i), versionPart(b, i)
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	s := parts[i]
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// parseVersion — «2024.08.06» у yt-dlp, «ffmpeg version 6.1.1 ...» у ffmpeg/ffprobe
+func parseVersion(out string) string {
+	first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(out), "\n", 2)[0])
+	if f := strings.Fields(first); len(f) >= 3 && f[1] == "version" {
+		return f[2]
+	}
+	return first
+}
+
+func runTool(ctx context.Context, bin string, args ...string) (string, error) {
+	ctxTO, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctxTO, bin, args...).Output()
+	return string(out), err
+}
+
+func countLines(s string) int {
+	n := 0
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,147 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/i18n"
+)
+
+// поддельные yt-dlp/ffmpeg/ffprobe: версии, список экстракторов и кодеков без libmp3lame
+const (
+	fakeYtDlp = `#!/bin/sh
+case "$1" in
+  --version) echo 2023.03.04;;
+  --list-extractors) printf 'youtube\nvimeo\n\ntwitch\n';;
+esac
+`
+	fakeFFmpegTool = `#!/bin/sh
+case "$*" in
+  *-encoders*) printf ' V..... libx264   H.264\n A..... aac       AAC\n A..... libopus   Opus\n';;
+  *) echo "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023"; echo "built with gcc";;
+esac
+`
+	fakeFFprobeTool = `#!/bin/sh
+echo "ffprobe version 6.1.1-3ubuntu5 Copyright (c) 2007-2023"
+`
+)
+
+func writeTool(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseVersion(t *testing.T) {
+	t.Parallel()
+	for out, want := range map[string]string{
+		"2024.08.06\n": "2024.08.06",
+		"ffmpeg version 6.1.1-3ubuntu5 Copyright (c)\nx": "6.1.1-3ubuntu5",
+		"ffprobe version n7.0 Copyright":                 "n7.0",
+		"  \n":                                           "",
+	} {
+		if got := parseVersion(out); got != want {
+			t.Errorf("parseVersion(%q) = %q; want %q", out, got, want)
+		}
+	}
+}
+
+func TestVersionOlder(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct {
+		v, min string
+		older  bool
+	}{
+		{"2023.03.04", "2024.01.01", true},
+		{"2024.08.06", "2024.01.01", false},
+		{"2024.8.6", "2024.08.06", false},
+		{"2024.08.06", "2024.08.06.1", true},
+		{"2024.08.06.232804", "2024.08.06", false},
+		{"2024.10.1", "2024.9.30", false},
+		{"6.1.1-3ubuntu5", "6.1", false},
+	} {
+		if got := versionOlder(c.v, c.min); got != c.older {
+			t.Errorf("versionOlder(%q, %q) = %v; want %v", c.v, c.min, got, c.older)
+		}
+	}
+}
+
+func TestResolveTool(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	ok := resolveTool(context.Background(), "yt-dlp", writeTool(t, dir, "yt-dlp", fakeYtDlp), "--version")
+	if !ok.Found() || ok.Version != "2023.03.04" || !filepath.IsAbs(ok.Path) {
+		t.Fatalf("resolveTool = %+v", ok)
+	}
+
+	missing := resolveTool(context.Background(), "yt-dlp", filepath.Join(dir, "nope"), "--version")
+	if missing.Found() || missing.Path != "" || missing.Err == "" {
+		t.Fatalf("missing tool = %+v", missing)
+	}
+
+	// бинарник есть, но не запускается с флагом версии
+	broken := resolveTool(context.Background(), "ffmpeg", writeTool(t, dir, "broken", "#!/bin/sh\nexit 3\n"), "-version")
+	if broken.Found() || broken.Path == "" || !strings.Contains(broken.Err, "ffmpeg -version") {
+		t.Fatalf("broken tool = %+v", broken)
+	}
+}
+
+func TestPreflightReportAndSupports(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DownloadDir:     dir,
+		YtDlpPath:       writeTool(t, dir, "yt-dlp", fakeYtDlp),
+		FFmpegPath:      writeTool(t, dir, "ffmpeg", fakeFFmpegTool),
+		YtDlpMinVersion: "2024.01.01",
+	}
+	writeTool(t, dir, "ffprobe", fakeFFprobeTool)
+	rep := NewRunner(cfg).Preflight(context.Background())
+
+	if rep.YtDlp.Version != "2023.03.04" || rep.FFmpeg.Version != "6.1.1-3ubuntu5" || !rep.FFprobe.Found() {
+		t.Fatalf("tools = %+v %+v %+v", rep.YtDlp, rep.FFmpeg, rep.FFprobe)
+	}
+	if rep.Extractors != 3 {
+		t.Fatalf("Extractors = %d; want 3", rep.Extractors)
+	}
+	en := i18n.Default().For("en")
+	if rep.OK() || len(rep.Problems) != 2 ||
+		!strings.Contains(rep.Problems[0].Text(en), "yt-dlp 2023.03.04 is older") || !strings.Contains(rep.Problems[1].Text(en), "libmp3lame") {
+		t.Fatalf("Problems = %+v", rep.Problems)
+	}
+	// отчёт целиком — на языке читателя
+	if text := rep.Text(en); !strings.Contains(text, "Status: degraded mode\n- yt-dlp 2023.03.04") || strings.Contains(text, "Состояние") {
+		t.Fatalf("en report = %q", text)
+	}
+	if text := rep.Text(i18n.Default().For("ru")); !strings.Contains(text, "ffmpeg без кодека libmp3lame") {
+		t.Fatalf("ru report = %q", text)
+	}
+
+	// без libmp3lame аудио-пресет отключается, видео остаётся
+	presets := config.DefaultPresets()
+	mp3, _ := presets.Get("audioMp3")
+	v720, _ := presets.Get("video720")
+	if rep.Supports(mp3) || !rep.Supports(v720) || rep.Has(FeatureMP3) || !rep.Has(FeatureH264) {
+		t.Fatalf("Supports: mp3=%v 720=%v", rep.Supports(mp3), rep.Supports(v720))
+	}
+	if got := presets.Filter(rep.Supports); len(got.All()) != len(presets.All())-1 {
+		t.Fatalf("Filter kept %d of %d presets", len(got.All()), len(presets.All()))
+	}
+
+	// без ffmpeg не работает ничего, что его требует; без проверки — всё доступно
+	none := &Report{FFmpeg: Tool{Name: "ffmpeg", Err: "not found"}}
+	if none.Has(FeatureFFmpeg) || none.Supports(mp3) || !none.Supports(v720) {
+		t.Fatalf("no ffmpeg: Has=%v mp3=%v", none.Has(FeatureFFmpeg), none.Supports(mp3))
+	}
+	var unchecked *Report
+	if !unchecked.Has(FeatureMP3) || !unchecked.Supports(mp3) {
+		t.Fatal("nil report must allow everything")
+	}
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// статусы live_status из yt-dlp
+const (
+	LiveNone     = "not_live"
+	LiveNow      = "is_live"
+	LiveUpcoming = "is_upcoming"
+	LiveWas      = "was_live"
+	LivePost     = "post_live"
+)
+
+// таймаут опроса метаданных: это один запрос страницы, а не загрузка, и ответ
+// пользователю его не ждёт — долгий опрос просто не меняет клавиатуру
+const probeTimeout = 15 * time.Second
+
+// Info — метаданные ролика без скачивания (yt-dlp -J)
+type Info struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	Uploader         string  `json:"uploader"`
+	Duration         float64 `json:"duration"`
+	LiveStatus       string  `json:"live_status"`
+	IsLive           bool    `json:"is_live"`
+	WasLive          bool    `json:"was_live"`
+	ReleaseTimestamp int64   `json:"release_timestamp"`
+}
+
+// Live — идёт трансляция
+func (i *Info) Live() bool { return i.LiveStatus == LiveNow || i.IsLive }
+
+// Upcoming — трансляция или премьера запланирована, но не началась
+func (i *Info) Upcoming() bool { return i.LiveStatus == LiveUpcoming }
+
+// Ended — запись завершённой трансляции: качается как обычное видео
+func (i *Info) Ended() bool { return i.LiveStatus == LiveWas || (i.LiveStatus == "" && i.WasLive) }
+
+// Processing — трансляция только что закончилась, YouTube ещё собирает запись
+func (i *Info) Processing() bool { return i.LiveStatus == LivePost }
+
+// StartsAt — запланированное время начала, если известно
+func (i *Info) StartsAt() (time.Time, bool) {
+	if i.ReleaseTimestamp <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(i.ReleaseTimestamp, 0), true
+}
+
+// Probe — опрос ссылки: live-статус, длительность, название. Одна попытка без перебора
+// прокси: загрузка всё равно пройдёт полную ротацию, а лишние запросы к YouTube
+// приближают rate limit
+func (r *Runner) Probe(ctx context.Context, url string) (*Info, error) {
+	if until, open := r.breaker.OpenUntil(); open {
+		return nil, &Error{Kind: KindRateLimited, Err: ErrCircuitOpen, RetryAt: until}
+	}
+
+	args := []string{"-J", "--skip-download", "--no-playlist", "--no-warnings", "--force-ipv4",
+		"--socket-timeout", "10", url}
+	p, viaProxy := r.pool.Pick(nil)
+	if viaProxy {
+		args = append([]string{"--proxy", p}, args...)
+	}
+	stdout, stderr, err := r.runOnce(ctx, r.bin(), args, viaProxy, probeTimeout)
+	if err != nil {
+		kind := Classify(stderr, err)
+		// «live event will begin» — это тоже ответ: премьера ещё не началась
+		if kind == KindLiveNotStarted {
+			return &Info{LiveStatus: LiveUpcoming}, nil
+		}
+		return nil, &Error{Kind: kind, Stderr: truncate(stderr, 2000), Err: err}
+	}
+
+	var info Info
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+		return nil, fmt.Errorf("parse yt-dlp json: %w", err)
+	}
+	return &info, nil
+}
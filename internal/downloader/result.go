@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"os"
+
+	"youtube-bot-simple/internal/queue"
+)
+
+// DownloadRequest — что скачать и как: задача очереди и параметры выдачи
+type DownloadRequest struct {
+	queue.Job
+	Thumbnail bool            // сделать превью для отправки (JPEG до 320px)
+	OnStep    func(StepEvent) // ход постобработки; лог пишется в любом случае
+}
+
+// Chapter — глава ролика
+type Chapter struct {
+	Start, End float64 // секунды
+	Title      string
+}
+
+// DownloadResult — готовый файл и всё, что о нём известно
+type DownloadResult struct {
+	Path   string
+	Size   int64
+	Ext    string
+	Preset string // ID пресета (вариант задачи)
+	Proxy  string // прокси без логина, через который скачан файл; пусто — напрямую
+
+	VideoID    string
+	Title      string
+	Uploader   string
+	WebpageURL string
+	Duration   float64 // секунды
+	Width      int
+	Height     int
+	FormatID   string
+	VideoCodec string // пусто — видео нет
+	AudioCodec string
+	Chapters   []Chapter
+
+	SponsorRemoved bool    // сегменты SponsorBlock вырезались и итог измерен
+	SponsorCut     float64 // сколько секунд вырезано (0 — сегментов не было)
+
+	Thumb string      // превью рядом с файлом, если просили; удаляется Release
+	Steps []StepEvent // итог каждого шага постобработки
+	Log   string      // stderr yt-dlp (хвост)
+}
+
+// StepFailed — шаг постобработки не удался и был пропущен
+func (res *DownloadResult) StepFailed(step string) bool {
+	for _, ev := range res.Steps {
+		if ev.Step == step && ev.State == StepFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Release — удалить служебные файлы результата (превью); сам файл остаётся
+func (res *DownloadResult) Release() {
+	if res != nil && res.Thumb != "" {
+		_ = os.Remove(res.Thumb)
+	}
+}
+
+// mediaMeta — поля из .info.json, которые пишет yt-dlp
+type mediaMeta struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	Uploader   string  `json:"uploader"`
+	UploadDate string  `json:"upload_date"`
+	WebpageURL string  `json:"webpage_url"`
+	Duration   float64 `json:"duration"`
+	FormatID   string  `json:"format_id"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	VCodec     string  `json:"vcodec"`
+	ACodec     string  `json:"acodec"`
+	Chapters   []struct {
+		Start float64 `json:"start_time"`
+		End   float64 `json:"end_time"`
+		Title string  `json:"title"`
+	} `json:"chapters"`
+}
+
+// fill — сведения из info.json
+func (res *DownloadResult) fill(m mediaMeta) {
+	res.VideoID, res.Title, res.Uploader, res.WebpageURL = m.ID, m.Title, m.Uploader, m.WebpageURL
+	res.Duration, res.Width, res.Height, res.FormatID = m.Duration, m.Width, m.Height, m.FormatID
+	// yt-dlp пишет «none» для отсутствующей дорожки
+	if m.VCodec != "none" {
+		res.VideoCodec = m.VCodec
+	}
+	if m.ACodec != "none" {
+		res.AudioCodec = m.ACodec
+	}
+	for _, c := range m.Chapters {
+		res.Chapters = append(res.Chapters, Chapter{Start: c.Start, End: c.End, Title: c.Title})
+	}
+}
+
+// apply — данные ffprobe по готовому файлу поверх info.json
+func (res *DownloadResult) apply(mi MediaInfo) {
+	res.VideoCodec, res.AudioCodec = mi.VideoCodec, mi.AudioCodec
+	res.Width, res.Height = mi.Width, mi.Height
+	if mi.Duration > 0 {
+		res.Duration = mi.Duration
+	}
+}
@@ -0,0 +1,31 @@
+package downloader
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResultFillAndApply(t *testing.T) {
+	t.Parallel()
+	var meta mediaMeta
+	raw := `{"id":"abc","title":"T","uploader":"U","duration":61.2,"format_id":"137+140","width":1920,"height":1080,
+		"vcodec":"vp09.00.40.08","acodec":"none","chapters":[{"start_time":0,"end_time":30,"title":"Intro"}]}`
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		t.Fatal(err)
+	}
+	res := &DownloadResult{}
+	res.fill(meta)
+	if res.VideoID != "abc" || res.FormatID != "137+140" || res.AudioCodec != "" || len(res.Chapters) != 1 || res.Chapters[0].Title != "Intro" {
+		t.Fatalf("fill = %+v", res)
+	}
+
+	// ffprobe по готовому файлу важнее: после перекодирования кодек другой
+	res.apply(MediaInfo{Width: 1280, Height: 720, Duration: 61, VideoCodec: "h264", AudioCodec: "aac"})
+	if res.VideoCodec != "h264" || res.Width != 1280 || res.Duration != 61 {
+		t.Fatalf("apply = %+v", res)
+	}
+	res.apply(MediaInfo{VideoCodec: "h264"})
+	if res.Duration != 61 {
+		t.Fatalf("zero ffprobe duration overwrote info.json value")
+	}
+}
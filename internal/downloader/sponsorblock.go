@@ -0,0 +1,53 @@
+package downloader
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"youtube-bot-simple/internal/queue"
+)
+
+// sponsorArgs — аргументы yt-dlp для SponsorBlock: вырезать сегменты или отметить их главами
+func sponsorArgs(sb queue.SponsorBlock, api string) []string {
+	cats := strings.Join(sb.Categories, ",")
+	if cats == "" {
+		cats = "sponsor"
+	}
+	var args []string
+	switch sb.Mode {
+	case queue.SponsorRemove:
+		args = []string{"--sponsorblock-remove", cats}
+	case queue.SponsorMark:
+		args = []string{"--sponsorblock-mark", cats, "--embed-chapters"}
+	default:
+		return nil
+	}
+	if api != "" {
+		args = append(args, "--sponsorblock-api", api)
+	}
+	return args
+}
+
+// sponsorCut — сколько секунд вырезал SponsorBlock: длительность из info.json минус
+// длительность скачанного файла до наших шагов (trimsilence тоже укорачивает файл);
+// false — измерить не удалось
+func (r *Runner) sponsorCut(ctx context.Context, work, path string) (float64, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(work, path)
+	}
+	_, meta := sidecars(work)
+	mi, err := r.inspect(ctx, path)
+	if err != nil {
+		log.Printf("[downloader] %v", err)
+		return 0, false
+	}
+	if meta.Duration <= 0 || mi.Duration <= 0 {
+		return 0, false
+	}
+	if cut := meta.Duration - mi.Duration; cut >= 1 {
+		return cut, true
+	}
+	return 0, true
+}
@@ -0,0 +1,53 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/queue"
+)
+
+func TestSponsorArgs(t *testing.T) {
+	t.Parallel()
+	const api = "http://127.0.0.1:8080/sb"
+	cases := []struct {
+		sb   queue.SponsorBlock
+		api  string
+		want string
+	}{
+		{queue.SponsorBlock{}, api, ""},
+		{queue.SponsorBlock{Mode: queue.SponsorRemove}, "", "--sponsorblock-remove sponsor"},
+		{queue.SponsorBlock{Mode: queue.SponsorRemove, Categories: []string{"sponsor", "intro"}}, api, "--sponsorblock-remove sponsor,intro --sponsorblock-api " + api},
+		{queue.SponsorBlock{Mode: queue.SponsorMark, Categories: []string{"selfpromo"}}, api, "--sponsorblock-mark selfpromo --embed-chapters --sponsorblock-api " + api},
+	}
+	for _, c := range cases {
+		if got := strings.Join(sponsorArgs(c.sb, c.api), " "); got != c.want {
+			t.Errorf("sponsorArgs(%+v, %q) = %q; want %q", c.sb, c.api, got, c.want)
+		}
+	}
+}
+
+func TestDownloadPassesSponsorBlockAPI(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	// поддельный yt-dlp записывает аргументы и падает: дальше загрузки идти не нужно
+	argsFile := filepath.Join(dir, "args")
+	ytdlp := writeTool(t, dir, "yt-dlp", "#!/bin/sh\necho \"$@\" > "+argsFile+"\nexit 1\n")
+	cfg := &config.Config{DownloadDir: dir, YtDlpPath: ytdlp, CmdTimeoutSec: 5, SponsorBlockAPI: "http://127.0.0.1:8080/sb"}
+	job := queue.Job{ID: "j", URL: "https://youtu.be/abc", Variant: queue.Variant("video720"),
+		SponsorBlock: queue.SponsorBlock{Mode: queue.SponsorRemove, Categories: []string{"sponsor", "outro"}}}
+	if _, err := NewRunner(cfg).Download(context.Background(), DownloadRequest{Job: job}); err == nil {
+		t.Fatal("fake yt-dlp should fail")
+	}
+	raw, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := string(raw); !strings.Contains(args, "--sponsorblock-remove sponsor,outro --sponsorblock-api http://127.0.0.1:8080/sb") {
+		t.Fatalf("yt-dlp args = %q; want SponsorBlock categories and API", args)
+	}
+}
@@ -0,0 +1,10 @@
+package downloader
+
+// subtitleArgs — субтитры на языке lang (свои или автоматические) дорожкой внутри mp4;
+// аудио-вариантам не нужны
+func subtitleArgs(lang string, audio bool) []string {
+	if lang == "" || audio {
+		return nil
+	}
+	return []string{"--write-subs", "--write-auto-subs", "--sub-langs", lang + ".*", "--embed-subs"}
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"youtube-bot-simple/internal/files"
+	"youtube-bot-simple/internal/queue"
+)
+
+// WorkDirName — подкаталог DownloadDir с рабочими директориями задач
+const WorkDirName = ".work"
+
+// workDir — рабочая директория задачи внутри DownloadDir (та же ФС — rename атомарен);
+// для задач из очереди путь выводится из ID, поэтому переживает перезапуск бота
+func (r *Runner) workDir(job queue.Job) (string, error) {
+	base := filepath.Join(r.cfg.DownloadDir, WorkDirName)
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return "", err
+	}
+	if job.ID == "" {
+		return os.MkdirTemp(base, "job-")
+	}
+	dir := filepath.Join(base, files.SanitizeFilename(job.ID))
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+// findOutput — итоговый файл в рабочей директории: самый большой из не временных
+func findOutput(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var best string
+	var bestSize int64 = -1
+	for _, e := range entries {
+		if e.IsDir() || files.IsPartial(e.Name()) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if fi.Size() > bestSize {
+			best, bestSize = filepath.Join(dir, e.Name()), fi.Size()
+		}
+	}
+	if best == "" {
+		return "", errors.New("failed to determine output file path")
+	}
+	return best, nil
+}
+
+// finalize — атомарно перенести готовый файл из рабочей директории в общий DownloadDir;
+// файл другой задачи с тем же именем не перезаписывается — тогда в имя добавляется ID задачи
+func (r *Runner) finalize(work, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(work, path)
+	}
+	// файл обязан лежать в директории этой задачи
+	if rel, err := filepath.Rel(work, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("output %q is outside job dir", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	final := filepath.Join(r.cfg.DownloadDir, name)
+	err := moveNoReplace(path, final)
+	if errors.Is(err, fs.ErrExist) {
+		ext := filepath.Ext(name)
+		final = filepath.Join(r.cfg.DownloadDir, strings.TrimSuffix(name, ext)+"_"+filepath.Base(work)+ext)
+		err = moveNoReplace(path, final)
+	}
+	if err != nil {
+		return "", fmt.Errorf("move output: %w", err)
+	}
+	return final, nil
+}
+
+// moveNoReplace — rename, который не затирает существующий файл: жёсткая ссылка
+// падает с ErrExist, если имя занято; без поддержки ссылок — проверка и rename
+func moveNoReplace(src, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil {
+		return os.Remove(src)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if _, statErr := os.Lstat(dst); statErr == nil {
+		return fs.ErrExist
+	}
+	return os.Rename(src, dst)
+}
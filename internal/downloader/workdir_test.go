@@ -0,0 +1,111 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/queue"
+)
+
+func TestWorkDirFinalize(t *testing.T) {
+	t.Parallel()
+	dl := t.TempDir()
+	r := NewRunner(&config.Config{DownloadDir: dl})
+
+	work, err := r.workDir(queue.Job{ID: "job-1"})
+	if err != nil {
+		t.Fatalf("workDir: %v", err)
+	}
+	if filepath.Dir(work) != filepath.Join(dl, WorkDirName) {
+		t.Fatalf("work dir %q not under %s", work, WorkDirName)
+	}
+	for name, data := range map[string]string{
+		"abc_video720_T.mp4":                 "final-content",
+		"abc_video720_T.f137.mp4":            "intermediate-longer-content",
+		"abc_video720_T.mp4.part":            "partial-longer-content-here",
+		"abc_video720_T.mp4.ytdl":            "x",
+		"abc_video720_T.part-Frag12":         "fragment-longer-content-here",
+		"abc_video720_T.f137.mp4-Frag3.part": "fragment-longer-content-here",
+	} {
+		if err := os.WriteFile(filepath.Join(work, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := findOutput(work)
+	if err != nil || filepath.Base(out) != "abc_video720_T.mp4" {
+		t.Fatalf("findOutput = %q, %v; want the merged mp4", out, err)
+	}
+	final, err := r.finalize(work, out)
+	if err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+	if final != filepath.Join(dl, "abc_video720_T.mp4") {
+		t.Fatalf("final = %q", final)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("source still present after move: %v", err)
+	}
+
+	// файл другой задачи с тем же именем не затирается
+	work2, err := r.workDir(queue.Job{ID: "job-2"})
+	if err != nil {
+		t.Fatalf("workDir: %v", err)
+	}
+	out2 := filepath.Join(work2, "abc_video720_T.mp4")
+	if err := os.WriteFile(out2, []byte("second"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	final2, err := r.finalize(work2, out2)
+	if err != nil || final2 != filepath.Join(dl, "abc_video720_T_job-2.mp4") {
+		t.Fatalf("finalize collision = %q, %v", final2, err)
+	}
+	if data, _ := os.ReadFile(final); string(data) != "final-content" {
+		t.Fatalf("first file overwritten: %q", data)
+	}
+
+	// путь вне директории задачи не принимаем
+	if _, err := r.finalize(work, filepath.Join(dl, "other.mp4")); err == nil {
+		t.Fatalf("finalize accepted a file outside the job dir")
+	}
+}
+
+func TestFindOutputTitleLooksLikeFragment(t *testing.T) {
+	t.Parallel()
+	work := t.TempDir()
+	// «-Frag» в названии ролика — не фрагмент yt-dlp
+	for name, data := range map[string]string{
+		"abc_video720_Half-Fragment.mp4":            "final-content",
+		"abc_video720_Half-Fragment.mp4.part-Frag1": "x",
+	} {
+		if err := os.WriteFile(filepath.Join(work, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := findOutput(work)
+	if err != nil || filepath.Base(out) != "abc_video720_Half-Fragment.mp4" {
+		t.Fatalf("findOutput = %q, %v", out, err)
+	}
+}
+
+func TestVariantTag(t *testing.T) {
+	t.Parallel()
+	sb := queue.SponsorBlock{Mode: queue.SponsorRemove, Categories: []string{"sponsor", "intro"}}
+	cases := []struct {
+		job  queue.Job
+		want string
+	}{
+		{queue.Job{Variant: queue.Variant("video720")}, "video720"},
+		{queue.Job{Variant: queue.Variant("video720"), LiveMinutes: 30}, "live30"},
+		{queue.Job{Variant: queue.Variant("video720"), SponsorBlock: sb}, "video720-sbremove.sponsor.intro"},
+		{queue.Job{Variant: queue.Variant("video720"), SponsorBlock: queue.SponsorBlock{Mode: queue.SponsorMark}, Subtitles: "en"}, "video720-sbmark-subsen"},
+	}
+	// настройки, которые меняют файл, дают разные имена — как и разные ключи кэша
+	for _, c := range cases {
+		if got := variantTag(c.job); got != c.want {
+			t.Errorf("variantTag(%+v) = %q; want %q", c.job, got, c.want)
+		}
+	}
+}
@@ -6,110 +6,262 @@ import (
     "context"
     "errors"
     "fmt"
+    "log"
     "os"
     "os/exec"
-    "path/filepath"
     "runtime"
     "strings"
+    "sync"
     "time"
 
     "youtube-bot-simple/internal/config"
+    "youtube-bot-simple/internal/proxy"
     "youtube-bot-simple/internal/queue"
 )
 
+// сколько разных прокси пробуем на одну задачу до прямого подключения
+const maxProxyAttempts = 3
+
 // Runner — минимальная обёртка над yt-dlp
 
 type Runner struct {
-	cfg *config.Config
+	cfg     *config.Config
+	pool    *proxy.Pool
+	breaker *Breaker
+	budget  *Budget
+
+	mu     sync.Mutex
+	report *Report
+}
+
+func NewRunner(cfg *config.Config) *Runner {
+	pool := proxy.NewPool(cfg.ProxyList, proxy.Strategy(cfg.ProxyStrategy), time.Duration(cfg.ProxyCooldownSec)*time.Second)
+	br := NewBreaker(cfg.BreakerThreshold, time.Duration(cfg.BreakerWindowSec)*time.Second, time.Duration(cfg.BreakerCooldownSec)*time.Second)
+	return &Runner{cfg: cfg, pool: pool, breaker: br, budget: NewBudget(cfg)}
 }
 
-func NewRunner(cfg *config.Config) *Runner { return &Runner{cfg: cfg} }
+// Budget — общий лимит скорости загрузок
+func (r *Runner) Budget() *Budget { return r.budget }
+
+// Breaker — предохранитель от rate limit YouTube, общий для всех задач
+func (r *Runner) Breaker() *Breaker { return r.breaker }
+
+// Download — запуск yt-dlp по запросу, постобработка и сбор сведений о готовом файле
+func (r *Runner) Download(ctx context.Context, req DownloadRequest) (*DownloadResult, error) {
+    job := req.Job
+    url, v := job.URL, job.Variant
+    // предохранитель открыт — не трогаем YouTube, задачу откладываем
+    if until, open := r.breaker.OpenUntil(); open {
+        return nil, &Error{Kind: KindRateLimited, Err: ErrCircuitOpen, RetryAt: until}
+    }
 
-// Download — запуск yt-dlp с нужными параметрами, возврат пути к файлу и его размера
-func (r *Runner) Download(ctx context.Context, url string, v queue.Variant) (string, int64, string, error) {
     args := []string{"-q", "--no-warnings", "--no-progress", "--no-playlist"}
     // базовые сетевые настройки — повышаем устойчивость
     args = append(args, "--retries", "5", "--retry-sleep", "2", "--socket-timeout", "15")
+    // докачка из .part при перезапуске (смена доли канала)
+    args = append(args, "--continue")
+    // info.json — метаданные для результата и шагов постобработки
+    args = append(args, "--write-info-json")
 
-	// шаблон файла и директория
-	template := "%(id)s_%(title).80s.%(ext)s"
-	args = append(args, "-o", template, "-P", r.cfg.DownloadDir)
+	// своя рабочая директория у каждой задачи: параллельные загрузки не пересекаются,
+	// а .part/.ytdl/фрагменты удаляются вместе с ней при ошибке или отмене
+	work, err := r.workDir(job)
+	if err != nil { return nil, fmt.Errorf("create job dir: %w", err) }
+	defer func() {
+		// остановка бота посреди задачи из очереди — оставляем .part для докачки после перезапуска
+		if ctx.Err() != nil && job.ID != "" { return }
+		os.RemoveAll(work)
+	}()
 
-	// ffmpeg и прокси при необходимости
+	// шаблон файла: вариант в имени, чтобы 360p и 720p одного ролика не перетирали друг друга
+	template := "%(id)s_" + variantTag(job) + "_%(title).80s.%(ext)s"
+	args = append(args, "-o", template, "-P", work)
+
+	// ffmpeg при необходимости; прокси подставляется на каждую попытку
     if r.cfg.FFmpegPath != "" { args = append(args, "--ffmpeg-location", r.cfg.FFmpegPath) }
-    if r.cfg.HTTPProxy != "" { args = append(args, "--proxy", r.cfg.HTTPProxy) }
     // IPv4 предпочтительнее в некоторых сетях
     args = append(args, "--force-ipv4")
 
-	// формат
-	switch v {
-	case queue.VarVideo360:
-		args = append(args, "-f", "bv*[height<=360]+ba/b[ext=mp4]/best[height<=360]", "--merge-output-format", "mp4")
-	case queue.VarVideo720:
-		args = append(args, "-f", "bv*[height<=720]+ba/b[ext=mp4]/best[height<=720]", "--merge-output-format", "mp4")
-	case queue.VarVideo1080:
-		args = append(args, "-f", "bv*[height<=1080]+ba/b[ext=mp4]/best[height<=1080]", "--merge-output-format", "mp4")
-	case queue.VarVideo1440:
-		args = append(args, "-f", "bv*[height<=1440]+ba/b[ext=mp4]/best[height<=1440]", "--merge-output-format", "mp4")
-	case queue.VarAudioMP3:
-		args = append(args, "-x", "--audio-format", "mp3")
-	default:
-		return "", 0, "", fmt.Errorf("unknown variant: %s", v)
+	// формат; трансляции пишем отдельно с жёстким лимитом длительности
+	timeout := time.Duration(r.cfg.CmdTimeoutSec) * time.Second
+	pr, known := r.cfg.PresetRegistry().Get(string(v))
+	// деградированный режим: без ffmpeg видео — одним готовым файлом, без слияния и шагов
+	rep := r.Report()
+	ffmpeg := rep.Has(FeatureFFmpeg)
+	if !ffmpeg && known && !pr.Audio() {
+		pr.Format, pr.MergeFormat, pr.Steps = "b[ext=mp4]/b", "", nil
+	}
+	if job.LiveMinutes > 0 {
+		args, timeout = r.liveArgs(args, job)
+	} else {
+		if !known {
+			return nil, fmt.Errorf("unknown variant: %s", v)
+		}
+		args = append(args, presetArgs(pr, job.AudioFormat)...)
+		args = append(args, r.codecArgs(pr)...)
+		if ffmpeg {
+			args = append(args, sponsorArgs(job.SponsorBlock, r.cfg.SponsorBlockAPI)...)
+			args = append(args, subtitleArgs(job.Subtitles, pr.Audio())...)
+		}
+		// идущую трансляцию обычной задачей не пишем: кнопку могли нажать до итогов опроса
+		args = append(args, "--match-filter", "!is_live", "--break-match-filters")
+	}
+	steps := pr.Steps
+	if r.cfg.CodecMode == config.CodecTranscode && rep.Has(FeatureH264) && !pr.Audio() && job.LiveMinutes == 0 && !hasStep(steps, "compat") {
+		// копия: срез шагов общий для всех задач пресета
+		steps = append(append([]config.PostStep(nil), steps...), config.PostStep{Name: "compat"})
 	}
+	args = append(args, postArgs(steps)...)
 
 	// хотим получить итоговый путь
 	args = append(args, "--print", "after_move:filepath")
 	args = append(args, url)
 
-    bin := r.cfg.YtDlpPath
-    if bin == "" { bin = "yt-dlp" }
-
-    // первая попытка: с текущими параметрами и (если указан) proxy
-    stdout, stderr, err := r.runOnce(ctx, bin, args, r.cfg.HTTPProxy != "")
+    // трансляцию нельзя перезапускать посреди записи — её доля канала фиксирована
+    var stdout, stderr, via string
+    if job.LiveMinutes > 0 {
+        stdout, stderr, via, err = r.runFixed(ctx, args, job.ID, url, timeout)
+    } else {
+        stdout, stderr, via, err = r.runThrottled(ctx, args, job.ID, url, timeout)
+    }
     if err != nil {
-        // если это DNS/прокси-ошибка — пробуем без прокси и с IPv4
-        if looksLikeDNS(stderr) || looksLikeDNS(err.Error()) || (r.cfg.HTTPProxy != "") {
-            // убираем --proxy из аргументов
-            var argsNoProxy []string
-            for i := 0; i < len(args); i++ {
-                if args[i] == "--proxy" {
-                    i++ // пропустить значение
-                    continue
-                }
-                argsNoProxy = append(argsNoProxy, args[i])
-            }
-            // повторная попытка без прокси; очищаем прокси-переменные окружения
-            stdout, stderr, err = r.runOnce(ctx, bin, argsNoProxy, false)
-        }
-        if err != nil {
-            return "", 0, "", fmt.Errorf("yt-dlp failed: %w; stderr=%s", err, truncate(stderr, 500))
-        }
+        kind := Classify(stderr, err)
+        if job.LiveMinutes == 0 && rejectedByFilter(err) { kind = KindLive }
+        log.Printf("[downloader] yt-dlp failed: kind=%s err=%v stderr=%s", kind, err, truncate(stderr, 500))
+        de := &Error{Kind: kind, Stderr: truncate(stderr, 2000), Err: err}
+        if until, open := r.breaker.Report(kind); open { de.RetryAt = until }
+        return nil, de
     }
+    r.breaker.Success()
+    log.Printf("[downloader] job=%s served via %s", job.ID, proxyLabel(via))
 
     path := parsePrintedPath([]byte(stdout))
 	if path == "" {
-		// fallback: поиск первой строки, похожей на файл
-		path = scanForExistingFile(r.cfg.DownloadDir, stdout)
-	}
-	if path == "" {
-		return "", 0, "", errors.New("failed to determine output file path")
+		// fallback: единственный готовый файл в директории задачи
+		if path, err = findOutput(work); err != nil { return nil, err }
 	}
 
-	// абсолютный путь
-	if !filepath.IsAbs(path) {
-		path = filepath.Join(r.cfg.DownloadDir, path)
+	res := &DownloadResult{Preset: string(v), Proxy: proxy.Redact(via), Log: truncate(stderr, 2000)}
+	if ffmpeg && job.LiveMinutes == 0 && job.SponsorBlock.Mode == queue.SponsorRemove {
+		res.SponsorCut, res.SponsorRemoved = r.sponsorCut(ctx, work, path)
 	}
+	onStep := func(ev StepEvent) {
+		if ev.State != StepProgress && ev.State != StepStart { res.Steps = append(res.Steps, ev) }
+		if req.OnStep != nil { req.OnStep(ev) }
+	}
+	if path, err = r.postProcess(ctx, job, work, path, steps, pr.Audio(), onStep); err != nil {
+		var se *StepError
+		stderr := ""
+		if errors.As(err, &se) { stderr = se.Stderr }
+		return nil, &Error{Kind: KindPostProcess, Stderr: stderr, Err: err}
+	}
+	_, meta := sidecars(work)
+	path, err = r.finalize(work, path)
+	if err != nil { return nil, err }
     fi, err := os.Stat(path)
-    if err != nil { return "", 0, "", err }
+    if err != nil { return nil, err }
+
+	res.Path, res.Size, res.Ext = path, fi.Size(), extOf(path)
+	res.fill(meta)
+	// ffprobe точнее info.json: после постобработки кодеки и размеры могли измениться
+	mi, err := r.inspect(ctx, path)
+	if err != nil {
+		log.Printf("[downloader] %v", err)
+		return res, nil
+	}
+	res.apply(mi)
+	if req.Thumbnail { res.Thumb = r.thumbnail(ctx, path, mi) }
+    return res, nil
+}
+
+// variantTag — метка варианта в имени файла; SponsorBlock и субтитры меняют содержимое,
+// поэтому тоже входят в метку, иначе параллельные задачи одного ролика делят итоговое имя
+func variantTag(job queue.Job) string {
+    tag := string(job.Variant)
+    if job.LiveMinutes > 0 { tag = fmt.Sprintf("live%d", job.LiveMinutes) }
+    if sb := job.SponsorBlock; sb.Mode != queue.SponsorOff {
+        tag += "-sb" + sb.Mode
+        if len(sb.Categories) > 0 { tag += "." + strings.Join(sb.Categories, ".") }
+    }
+    if job.Subtitles != "" { tag += "-subs" + job.Subtitles }
+    return tag
+}
+
+// presetArgs — формат, контейнер и дополнительные аргументы пресета;
+// формат аудио из настроек пользователя заменяет --audio-format пресета
+func presetArgs(p config.Preset, audioFormat string) []string {
+    args := []string{"-f", p.Format}
+    if p.MergeFormat != "" { args = append(args, "--merge-output-format", p.MergeFormat) }
+    extra := append([]string(nil), p.ExtraArgs...)
+    for i := 0; p.Audio() && audioFormat != "" && i+1 < len(extra); i++ {
+        if extra[i] == "--audio-format" { extra[i+1] = audioFormat }
+    }
+    return append(args, extra...)
+}
+
+// codecArgs — при равном разрешении предпочесть H.264/AAC и положить moov в начало mp4
+func (r *Runner) codecArgs(p config.Preset) []string {
+    if r.cfg.CodecMode == config.CodecAny || p.Audio() { return nil }
+    return []string{"-S", "res,vcodec:h264,acodec:aac", "--postprocessor-args", "Merger+ffmpeg_o:-movflags +faststart"}
+}
+
+func hasStep(steps []config.PostStep, name string) bool {
+    for _, s := range steps {
+        if s.Name == name { return true }
+    }
+    return false
+}
+
+// bin — путь к yt-dlp
+func (r *Runner) bin() string {
+    if r.cfg.YtDlpPath != "" { return r.cfg.YtDlpPath }
+    return "yt-dlp"
+}
+
+// runWithProxies — попытки через прокси из пула, затем напрямую без прокси;
+// via — прокси, через который прошёл запуск (пусто — напрямую)
+func (r *Runner) runWithProxies(ctx context.Context, bin string, args []string, jobID, url string, timeout time.Duration) (stdout, stderr, via string, err error) {
+    tried := make(map[string]bool)
+    attempts := r.pool.Len()
+    if attempts > maxProxyAttempts { attempts = maxProxyAttempts }
+    for i := 0; i < attempts; i++ {
+        p, ok := r.pool.Pick(tried)
+        if !ok { break }
+        tried[p] = true
+
+        stdout, stderr, err = r.runOnce(ctx, bin, append([]string{"--proxy", p}, args...), true, timeout)
+        // отмена задачи — не вина прокси
+        if err != nil && ctx.Err() != nil { return stdout, stderr, "", err }
+        r.pool.Record(jobID, url, p, err == nil)
+        if err == nil {
+            r.pool.Success(p)
+            return stdout, stderr, p, nil
+        }
 
-	ext := strings.ToLower(filepath.Ext(path))
-	if strings.HasPrefix(ext, ".") { ext = ext[1:] }
-    return path, fi.Size(), ext, nil
+        // rate limit, проверка «не робот» и сеть — прокси в cooldown и пробуем следующий
+        network := networkKind(Classify(stderr, err))
+        r.pool.Failure(p, truncate(lastLine(stderr), 200), network)
+        log.Printf("[downloader] proxy %s failed: %v", proxy.Redact(p), err)
+        if !network { break }
+    }
+
+    // напрямую: без прокси и без прокси-переменных окружения
+    stdout, stderr, err = r.runOnce(ctx, bin, args, false, timeout)
+    r.pool.Record(jobID, url, "", err == nil)
+    if err == nil && len(tried) > 0 {
+        log.Printf("[downloader] served directly after %d proxy attempts", len(tried))
+    }
+    return stdout, stderr, "", err
+}
+
+// proxyLabel — прокси без логина для логов; пусто — напрямую
+func proxyLabel(p string) string {
+    if p == "" { return "direct" }
+    return proxy.Redact(p)
 }
 
 // runOnce — запуск yt-dlp с таймаутом и управлением окружением
-func (r *Runner) runOnce(ctx context.Context, bin string, args []string, allowProxyEnv bool) (stdoutStr, stderrStr string, err error) {
-    to := time.Duration(r.cfg.CmdTimeoutSec) * time.Second
+func (r *Runner) runOnce(ctx context.Context, bin string, args []string, allowProxyEnv bool, to time.Duration) (stdoutStr, stderrStr string, err error) {
     ctxTO, cancel := context.WithTimeout(ctx, to)
     defer cancel()
 
@@ -127,6 +279,10 @@ func (r *Runner) runOnce(ctx context.Context, bin string, args []string, allowPr
     cmd.Env = env
 
     err = cmd.Run()
+    // таймаут команды отличаем от отмены родительского контекста
+    if err != nil && ctxTO.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+        err = fmt.Errorf("%w after %s: %v", context.DeadlineExceeded, to, err)
+    }
     return stdout.String(), stderr.String(), err
 }
 
@@ -168,6 +324,12 @@ func looksLikeDNS(s string) bool {
     return false
 }
 
+func lastLine(s string) string {
+    s = strings.TrimSpace(s)
+    if i := strings.LastIndexByte(s, '\n'); i >= 0 { return s[i+1:] }
+    return s
+}
+
 func parsePrintedPath(b []byte) string {
 	s := bufio.NewScanner(bytes.NewReader(b))
 	var last string
@@ -178,17 +340,6 @@ func parsePrintedPath(b []byte) string {
 	return last
 }
 
-func scanForExistingFile(dir, out string) string {
-	s := bufio.NewScanner(strings.NewReader(out))
-	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
-		if line == "" { continue }
-		p := filepath.Join(dir, line)
-		if _, err := os.Stat(p); err == nil { return p }
-	}
-	return ""
-}
-
 func truncate(s string, n int) string {
 	if len(s) <= n { return s }
 	return s[:n]
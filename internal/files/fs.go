@@ -6,6 +6,7 @@ import (
     "log"
     "os"
     "path/filepath"
+    "regexp"
     "strings"
     "time"
 )
@@ -97,6 +98,21 @@ func SafeJoin(baseDir, name string) (string, error) {
     return p, nil
 }
 
+// промежуточные потоки yt-dlp до слияния: name.f137.mp4
+var formatPartRe = regexp.MustCompile(`\.f\d+\.[a-z0-9]+$`)
+
+// фрагменты HLS/DASH: name.mp4.part-Frag12, name.f137.mp4-Frag3.part
+var fragmentRe = regexp.MustCompile(`-frag\d+(\.part)?$`)
+
+// IsPartial — незавершённый/промежуточный файл yt-dlp (.part, .ytdl, фрагменты)
+func IsPartial(name string) bool {
+    n := strings.ToLower(name)
+    for _, suf := range []string{".part", ".ytdl", ".temp", ".tmp"} {
+        if strings.HasSuffix(n, suf) { return true }
+    }
+    return fragmentRe.MatchString(n) || formatPartRe.MatchString(n)
+}
+
 // StartCleanup — фоновая очистка старых файлов
 func StartCleanup(ctx context.Context, dir string, ttlHours int) {
     if ttlHours <= 0 { return }
@@ -117,6 +133,57 @@ func StartCleanup(ctx context.Context, dir string, ttlHours int) {
     }()
 }
 
+// StartPartialCleanup — фоновая очистка брошенных рабочих директорий загрузок
+func StartPartialCleanup(ctx context.Context, dir string, ttlHours int) {
+    if ttlHours <= 0 { return }
+    go func() {
+        ticker := time.NewTicker(time.Hour)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                if _, err := CleanupStaleDirs(dir, time.Duration(ttlHours)*time.Hour); err != nil && !os.IsNotExist(err) {
+                    log.Printf("[cleanup] partials failed: %v", err)
+                }
+            }
+        }
+    }()
+}
+
+// CleanupStaleDirs — удалить поддиректории, в которых ничего не менялось дольше olderThan
+func CleanupStaleDirs(dir string, olderThan time.Duration) (int, error) {
+    cutoff := time.Now().Add(-olderThan)
+    entries, err := os.ReadDir(dir)
+    if err != nil { return 0, err }
+    removed := 0
+    for _, e := range entries {
+        if !e.IsDir() { continue }
+        p := filepath.Join(dir, e.Name())
+        if newestModTime(p).After(cutoff) { continue }
+        if err := os.RemoveAll(p); err != nil {
+            log.Printf("[cleanup] remove %s failed: %v", p, err)
+            continue
+        }
+        removed++
+    }
+    return removed, nil
+}
+
+// newestModTime — самое позднее время изменения внутри директории (.part дописываются)
+func newestModTime(dir string) time.Time {
+    var newest time.Time
+    _ = filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+        if err != nil { return nil }
+        if fi, err := d.Info(); err == nil && fi.ModTime().After(newest) {
+            newest = fi.ModTime()
+        }
+        return nil
+    })
+    return newest
+}
+
 // CleanupOnce — разовая очистка файлов старше заданного возраста
 func CleanupOnce(dir string, olderThan time.Duration) (int, error) {
     cutoff := time.Now().Add(-olderThan)
@@ -0,0 +1,160 @@
+package i18n
+
+var en = Catalog{
+	"start":         "Hi! Send a YouTube link, then pick a variant (360p/720p/1080p/1440p/MP3).",
+	"help.intro":    "Send a link to a YouTube video or Short. Once you pick a variant, the bot downloads it and sends the file. Size limit is about {mb} MB.",
+	"help.commands": "Commands:",
+	"link.invalid":  "This doesn't look like a YouTube link. Send a link like https://youtu.be/... or https://youtube.com/...",
+	"choose":        "Choose what to download:",
+	"queued":        "Queued: {label}",
+
+	"cmd.start":             "Get started",
+	"cmd.help":              "Help and command list",
+	"cmd.sponsorblock":      "Default SponsorBlock mode",
+	"cmd.sponsorblock.args": "off|remove|mark [categories]",
+	"cmd.caption":           "File caption template",
+	"cmd.caption.args":      "[template|reset]",
+	"cmd.group":             "Group settings",
+	"cmd.group.args":        "[variants|default|trigger value]",
+	"cmd.language":          "Bot language",
+	"cmd.language.args":     "[ru|en|auto]",
+	"cmd.settings":          "Settings: quality, auto download, subtitles",
+	"cmd.status":            "Bot status (admins)",
+	"cmd.admin_only":        "This command is for admins only.",
+	"cmd.private_only":      "This command only works in a private chat.",
+	"cmd.group_only":        "This command only works in groups.",
+
+	"language.name":    "English",
+	"language.current": "Language: {lang}. Change it: /language ru|en|auto",
+	"language.auto":    "{lang} (from your Telegram language)",
+	"language.unknown": "Unknown language {lang}. Available: {langs}, auto",
+	"language.saved":   "Language: {lang}.",
+
+	"button.starting": "Starting download…",
+	"button.invalid":  "This button is broken. Please send the link again.",
+	"button.expired":  "This button has expired. Please send the link again.",
+	"button.gone":     "This variant is no longer available. Please send the link again.",
+	"trigger.admins":  "Only admins can start downloads in this group.",
+
+	"live.prompt":       "This is a live stream. What should be recorded?",
+	"live.button":       "🔴 {n} min",
+	"live.button_start": "⏮ From the start (up to {n} min)",
+	"live.queued":       "Stream recording queued: {what}",
+	"live.from_start":   "from the start, up to {n} min",
+	"live.next.one":     "the next {n} minute",
+	"live.next.other":   "the next {n} minutes",
+	"live.ended.one":    "This is a recording of a past stream ({n} minute). What should be downloaded?",
+	"live.ended.other":  "This is a recording of a past stream ({n} minutes). What should be downloaded?",
+	"live.processing":   "The stream has just ended and YouTube is still processing the recording — the file may be incomplete. What should be downloaded?",
+	"upcoming":          "The stream or premiere hasn't started yet. Send the link once it starts.",
+	"upcoming.at":       "The stream or premiere hasn't started yet (starts {time}). Send the link once it starts.",
+
+	"file.too_large":     "The file is too large for the bot to send. Try 360p or Audio MP3.",
+	"send.failed":        "Couldn't send the file.",
+	"send.failed_inline": "Couldn't send the file. Open the bot with the «Open bot» button and try again.",
+	"delayed":            "YouTube is temporarily limiting downloads. The job is postponed until {time}; the file will arrive automatically.",
+	"resumed":            "The bot restarted; resuming download: {label}",
+	"resume.gave_up":     "Couldn't finish the download after several bot restarts: {label}. Please send the link again.",
+	"status.queue.one":   "Queue: {n} job, workers: {workers}",
+	"status.queue.other": "Queue: {n} jobs, workers: {workers}",
+	"status.paused":      "Queue paused until {time}",
+
+	"postprocess.partial": "Some processing steps failed ({steps}); the file was sent without them.",
+	"compat.fallback":     "Couldn't re-encode the video for Telegram, so the original file was sent as a document.",
+	"admin.step_failed":   "[admin] Job {job} ({url}): processing step {step} failed: {error}",
+
+	"admin.rate_limited": "[admin] YouTube is rate-limiting requests ({kind}). Downloads are paused until {time}.",
+	"admin.degraded":     "[admin] The bot started in degraded mode:\n{report}",
+
+	"preflight.checked":         "Environment check: {time}",
+	"preflight.extractors":      "yt-dlp extractors: {n}",
+	"preflight.encoders":        "Encoders: {list}",
+	"preflight.ok":              "Status: OK",
+	"preflight.degraded":        "Status: degraded mode",
+	"preflight.ytdlp_old":       "yt-dlp {version} is older than {min}; update it: yt-dlp -U",
+	"preflight.ytdlp_missing":   "yt-dlp not found: {error}",
+	"preflight.no_encoder":      "ffmpeg lacks the {encoder} encoder",
+	"preflight.ffmpeg_missing":  "ffmpeg not found (merging video/audio and MP3 won't work): {error}",
+	"preflight.ffprobe_missing": "ffprobe not found: {error}",
+
+	"status.unchecked":       "The environment check hasn't run",
+	"status.breaker_open":    "Circuit breaker: open until {time}",
+	"status.breaker_closed":  "Circuit breaker: closed",
+	"status.bandwidth.one":   "Bandwidth limit: {kbps} KB/s for {n} download",
+	"status.bandwidth.other": "Bandwidth limit: {kbps} KB/s for {n} downloads",
+	"status.proxy":           "Proxy {proxy}: ok={ok} fail={fail} score={score}",
+	"status.proxy_paused":    "(paused until {time})",
+	"status.direct":          "direct",
+
+	"error.private":     "Download failed: the video is private. Ask the author for access or send another link.",
+	"error.removed":     "Download failed: the video was removed or is unavailable. Check the link.",
+	"error.geo":         "Download failed: the video isn't available in the bot server's region.",
+	"error.age":         "Download failed: the video is age-restricted and can't be fetched without a YouTube account.",
+	"error.members":     "Download failed: the video is for channel members only.",
+	"error.not_started": "Download failed: the stream or premiere hasn't started yet. Send the link once it starts.",
+	"error.rate_limit":  "YouTube is temporarily rate-limiting requests. Try again in a few minutes.",
+	"error.bot_check":   "YouTube asked for an \"I'm not a robot\" check. Try again later.",
+	"error.network":     "Network error while downloading. Try again a bit later.",
+	"error.disk_full":   "The server is out of disk space. Try again later.",
+	"error.timeout":     "The download took too long and was stopped. Try a lower quality or MP3.",
+	"error.postprocess": "The video was downloaded, but processing the file failed. Try another variant.",
+	"error.unknown":     "Couldn't download the video. Try again or pick another variant.",
+	"error.step":        "Processing step {step} failed.",
+	"error.live":        "Download failed: this is a live stream. Send the link again and choose how many minutes to record.",
+
+	"size.gb":            "{n} GB",
+	"size.mb":            "{n} MB",
+	"size.kb":            "{n} KB",
+	"caption.more.one":   "… {n} more chapter",
+	"caption.more.other": "… {n} more chapters",
+	"caption.admins":     "Only group admins can change the caption template.",
+	"caption.current":    "Caption template ({mode}):\n{template}\n\nFields: {fields}\n/caption reset to use the shared one",
+	"caption.invalid":    "The template doesn't work: {err}",
+	"caption.reset":      "Caption template reset.",
+	"caption.saved":      "Caption template saved.",
+
+	"sponsor.current":          "SponsorBlock: {mode}.\nUsage: /sponsorblock off|remove|mark [categories]\nCategories: {categories}",
+	"sponsor.bad_mode":         "Mode: off, remove or mark.",
+	"sponsor.unknown_category": "Unknown category {category}. Available: {categories}, all",
+	"sponsor.saved":            "SponsorBlock: {mode}.",
+	"sponsor.button":           "SponsorBlock: {mode}",
+	"sponsor.none":             "SponsorBlock: no segments found",
+	"sponsor.cut":              "Cut by SponsorBlock: {time}",
+	"sponsor.remove":           "cut",
+	"sponsor.mark":             "chapters",
+	"sponsor.off":              "off",
+
+	"group.change":      "Change: /group variants 720,mp3|all, /group default 720|off, /group trigger all|admins",
+	"group.admins":      "Only group admins can change group settings.",
+	"group.usage":       "Usage: /group variants|default|trigger value",
+	"group.unknown":     "Unknown variant {code}. Available: {codes}",
+	"group.bad_trigger": "Who starts downloads: all or admins.",
+	"group.bad_setting": "Settings: variants, default, trigger.",
+	"group.summary":     "Variants: {variants}\nDefault: {default}\nDownloads started by: {who}",
+	"group.no_default":  "none (buttons)",
+	"group.who_all":     "all members",
+	"group.who_admins":  "admins only",
+
+	"inline.open_bot":    "Open bot",
+	"inline.title":       "Download: {label}",
+	"inline.description": "The file will appear in the message once downloaded",
+	"inline.loading":     "⏳ Downloading: {label}",
+	"inline.open_yt":     "Open on YouTube",
+	"inline.expired":     "The link has expired. Type the query again.",
+	"inline.live":        "Streams and premieres are only available in the chat with the bot.",
+	"inline.start_bot":   "Can't deliver the file: you haven't started the bot yet. Press «Open bot», send /start and repeat the query.",
+
+	"settings.title":              "Settings. Tap a row to change it:",
+	"settings.variant":            "Default quality: {value}",
+	"settings.variant_none":       "not set",
+	"settings.auto":               "Download without keyboard: {value}",
+	"settings.caption":            "File captions: {value}",
+	"settings.subs":               "Subtitles: {value}",
+	"settings.audio":              "Audio format: {value}",
+	"settings.lang":               "Language: {value}",
+	"settings.lang_auto":          "auto",
+	"settings.on":                 "on",
+	"settings.off":                "off",
+	"settings.auto_needs_variant": "Pick a default quality first.",
+	"settings.saved":              "Saved",
+}
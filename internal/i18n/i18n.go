@@ -0,0 +1,150 @@
+// Package i18n — каталоги сообщений бота: языки, формы множественного числа и подстановки.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Catalog — сообщения одного языка: ключ → текст с подстановками {name};
+// формы множественного числа лежат под ключами key.one, key.few, key.many, key.other
+type Catalog map[string]string
+
+// Bundle — каталоги всех языков; недостающий ключ берётся из языка по умолчанию
+type Bundle struct {
+	fallback string
+	catalogs map[string]Catalog
+}
+
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{fallback: fallback, catalogs: make(map[string]Catalog)}
+}
+
+// Default — встроенные каталоги: ru (по умолчанию) и en
+func Default() *Bundle {
+	b := NewBundle("ru")
+	b.Add("ru", ru)
+	b.Add("en", en)
+	return b
+}
+
+func (b *Bundle) Add(lang string, c Catalog) { b.catalogs[lang] = c }
+
+// Fallback — язык по умолчанию
+func (b *Bundle) Fallback() string { return b.fallback }
+
+// Langs — поддерживаемые языки по алфавиту
+func (b *Bundle) Langs() []string {
+	out := make([]string, 0, len(b.catalogs))
+	for l := range b.catalogs {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Match — язык из language_code Telegram («en-US» → «en»); неизвестный — язык по умолчанию
+func (b *Bundle) Match(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if _, ok := b.catalogs[code]; ok {
+		return code
+	}
+	return b.fallback
+}
+
+// For — переводчик на язык lang
+func (b *Bundle) For(lang string) Localizer { return Localizer{b: b, Lang: b.Match(lang)} }
+
+// std — каталоги для нулевого Localizer: язык по умолчанию встроенного набора
+var std = Default()
+
+// Localizer — сообщения на одном языке; нулевое значение говорит на языке по умолчанию
+type Localizer struct {
+	b    *Bundle
+	Lang string
+}
+
+// T — сообщение по ключу; kv — пары имя, значение для подстановок {имя}
+func (l Localizer) T(key string, kv ...any) string {
+	s, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	return expand(s, kv)
+}
+
+// N — сообщение в форме множественного числа для n; {n} подставляется само
+func (l Localizer) N(key string, n int, kv ...any) string {
+	kv = append([]any{"n", n}, kv...)
+	lang := l.Lang
+	if lang == "" {
+		lang = l.bundle().fallback
+	}
+	for _, form := range []string{pluralForm(lang, n), "other", "many"} {
+		if s, ok := l.lookup(key + "." + form); ok {
+			return expand(s, kv)
+		}
+	}
+	return key
+}
+
+// Has — ключ есть в каталоге языка или языка по умолчанию
+func (l Localizer) Has(key string) bool {
+	_, ok := l.lookup(key)
+	return ok
+}
+
+func (l Localizer) bundle() *Bundle {
+	if l.b == nil {
+		return std
+	}
+	return l.b
+}
+
+func (l Localizer) lookup(key string) (string, bool) {
+	b := l.bundle()
+	if s, ok := b.catalogs[l.Lang][key]; ok {
+		return s, true
+	}
+	s, ok := b.catalogs[b.fallback][key]
+	return s, ok
+}
+
+// expand — подстановка {name}; неизвестные плейсхолдеры остаются как есть
+func expand(s string, kv []any) string {
+	if len(kv) < 2 || !strings.Contains(s, "{") {
+		return s
+	}
+	pairs := make([]string, 0, len(kv))
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(kv[i])+"}", fmt.Sprint(kv[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// pluralForm — категория CLDR для целого n: ru — one/few/many, остальные — one/other
+func pluralForm(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	switch lang {
+	case "ru", "uk":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+}
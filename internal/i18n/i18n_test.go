@@ -0,0 +1,87 @@
+package i18n
+
+import "testing"
+
+func TestPluralForms(t *testing.T) {
+	cases := []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{"ru", 1, "one"}, {"ru", 2, "few"}, {"ru", 5, "many"}, {"ru", 11, "many"},
+		{"ru", 12, "many"}, {"ru", 21, "one"}, {"ru", 22, "few"}, {"ru", 111, "many"},
+		{"en", 1, "one"}, {"en", 0, "other"}, {"en", 21, "other"},
+	}
+	for _, c := range cases {
+		if got := pluralForm(c.lang, c.n); got != c.want {
+			t.Errorf("pluralForm(%s, %d) = %s, want %s", c.lang, c.n, got, c.want)
+		}
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	b := Default()
+	if got := b.Match("en-US"); got != "en" {
+		t.Fatalf("Match(en-US) = %s", got)
+	}
+	if got := b.Match("de"); got != "ru" {
+		t.Fatalf("Match(de) = %s", got)
+	}
+
+	ru, en := b.For("ru"), b.For("en")
+	if got := ru.T("queued", "label", "HD 720p"); got != "Задача поставлена в очередь: HD 720p" {
+		t.Fatalf("ru queued = %q", got)
+	}
+	if got := en.T("queued", "label", "HD 720p"); got != "Queued: HD 720p" {
+		t.Fatalf("en queued = %q", got)
+	}
+	if got := ru.N("live.next", 3); got != "следующие 3 минуты" {
+		t.Fatalf("ru plural = %q", got)
+	}
+	if got := en.N("live.next", 1); got != "the next 1 minute" {
+		t.Fatalf("en plural = %q", got)
+	}
+
+	// нет ключа в языке — берётся язык по умолчанию; нет нигде — сам ключ
+	b.Add("xx", Catalog{"start": "xx"})
+	xx := b.For("xx")
+	if got := xx.T("choose"); got != ru.T("choose") {
+		t.Fatalf("fallback = %q", got)
+	}
+	if got := xx.T("no.such.key"); got != "no.such.key" {
+		t.Fatalf("missing = %q", got)
+	}
+	// нулевой Localizer — язык по умолчанию с его правилами множественного числа
+	var zero Localizer
+	if got := zero.N("caption.more", 5); got != "… ещё 5 глав" {
+		t.Fatalf("zero = %q", got)
+	}
+}
+
+// каталоги должны покрывать одни и те же сообщения
+func TestCatalogsComplete(t *testing.T) {
+	base := func(c Catalog) map[string]bool {
+		out := map[string]bool{}
+		for k := range c {
+			for _, f := range []string{".one", ".few", ".many", ".other"} {
+				if len(k) > len(f) && k[len(k)-len(f):] == f {
+					k = k[:len(k)-len(f)]
+					break
+				}
+			}
+			out[k] = true
+		}
+		return out
+	}
+	r, e := base(ru), base(en)
+	for k := range r {
+		if !e[k] {
+			t.Errorf("en misses %s", k)
+		}
+	}
+	for k := range e {
+		if !r[k] {
+			t.Errorf("ru misses %s", k)
+		}
+	}
+}
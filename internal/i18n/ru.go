@@ -0,0 +1,165 @@
+package i18n
+
+var ru = Catalog{
+	"start":         "Привет! Пришлите ссылку на YouTube, затем выберите вариант (360p/720p/1080p/1440p/MP3).",
+	"help.intro":    "Скидывайте ссылку на видео YouTube или Shorts. После выбора варианта бот скачает и пришлёт файл. Ограничение по размеру ~{mb} МБ.",
+	"help.commands": "Команды:",
+	"link.invalid":  "Похоже, это не ссылка на YouTube. Отправьте ссылку вида https://youtu.be/... или https://youtube.com/...",
+	"choose":        "Выберите вариант загрузки:",
+	"queued":        "Задача поставлена в очередь: {label}",
+
+	"cmd.start":             "Начать работу",
+	"cmd.help":              "Справка и список команд",
+	"cmd.sponsorblock":      "SponsorBlock по умолчанию",
+	"cmd.sponsorblock.args": "off|remove|mark [категории]",
+	"cmd.caption":           "Шаблон подписи к файлам",
+	"cmd.caption.args":      "[шаблон|reset]",
+	"cmd.group":             "Настройки группы",
+	"cmd.group.args":        "[variants|default|trigger значение]",
+	"cmd.language":          "Язык бота",
+	"cmd.language.args":     "[ru|en|auto]",
+	"cmd.settings":          "Настройки: качество, автозагрузка, субтитры",
+	"cmd.status":            "Состояние бота (администраторы)",
+	"cmd.admin_only":        "Команда доступна только администраторам.",
+	"cmd.private_only":      "Команда работает только в личном чате.",
+	"cmd.group_only":        "Команда работает только в группах.",
+
+	"language.name":    "русский",
+	"language.current": "Язык: {lang}. Изменить: /language ru|en|auto",
+	"language.auto":    "{lang} (по языку Telegram)",
+	"language.unknown": "Неизвестный язык {lang}. Доступны: {langs}, auto",
+	"language.saved":   "Язык: {lang}.",
+
+	"button.starting": "Начинаю загрузку…",
+	"button.invalid":  "Кнопка некорректна. Пришлите ссылку ещё раз.",
+	"button.expired":  "Кнопка устарела. Пришлите ссылку ещё раз.",
+	"button.gone":     "Этот вариант больше недоступен. Пришлите ссылку ещё раз.",
+	"trigger.admins":  "Загрузки в этой группе запускают только администраторы.",
+
+	"live.prompt":       "Это прямая трансляция. Что записать?",
+	"live.button":       "🔴 {n} мин",
+	"live.button_start": "⏮ С начала (до {n} мин)",
+	"live.queued":       "Запись трансляции поставлена в очередь: {what}",
+	"live.from_start":   "с начала эфира, до {n} мин",
+	"live.next.one":     "следующую {n} минуту",
+	"live.next.few":     "следующие {n} минуты",
+	"live.next.many":    "следующие {n} минут",
+	"live.ended.one":    "Это запись прошедшей трансляции ({n} минута). Что скачать?",
+	"live.ended.few":    "Это запись прошедшей трансляции ({n} минуты). Что скачать?",
+	"live.ended.many":   "Это запись прошедшей трансляции ({n} минут). Что скачать?",
+	"live.processing":   "Трансляция только что закончилась, YouTube ещё обрабатывает запись — файл может оказаться неполным. Что скачать?",
+	"upcoming":          "Трансляция или премьера ещё не началась. Пришлите ссылку после начала.",
+	"upcoming.at":       "Трансляция или премьера ещё не началась (начало {time}). Пришлите ссылку после начала.",
+
+	"file.too_large":     "Файл слишком большой для отправки ботом. Попробуйте качество 360p или Аудио MP3.",
+	"send.failed":        "Не удалось отправить файл.",
+	"send.failed_inline": "Не удалось отправить файл. Откройте бота кнопкой «Открыть в боте» и повторите.",
+	"delayed":            "YouTube временно ограничивает загрузки. Задача отложена до {time} — файл придёт автоматически.",
+	"resumed":            "Бот перезапустился — продолжаю загрузку: {label}",
+	"resume.gave_up":     "Загрузку не удалось завершить за несколько перезапусков бота: {label}. Пришлите ссылку ещё раз.",
+	"status.queue.one":   "Очередь: {n} задача, воркеров: {workers}",
+	"status.queue.few":   "Очередь: {n} задачи, воркеров: {workers}",
+	"status.queue.many":  "Очередь: {n} задач, воркеров: {workers}",
+	"status.paused":      "Очередь на паузе до {time}",
+
+	"postprocess.partial": "Не все шаги обработки удались ({steps}) — файл отправлен без них.",
+	"compat.fallback":     "Перекодировать видео для Telegram не удалось — отправил исходный файл документом.",
+	"admin.step_failed":   "[admin] Задача {job} ({url}): шаг обработки {step} не удался: {error}",
+
+	"admin.rate_limited": "[admin] YouTube ограничивает запросы ({kind}). Загрузки приостановлены до {time}.",
+	"admin.degraded":     "[admin] Бот запущен в деградированном режиме:\n{report}",
+
+	"preflight.checked":         "Проверка окружения: {time}",
+	"preflight.extractors":      "Экстракторов yt-dlp: {n}",
+	"preflight.encoders":        "Кодеки: {list}",
+	"preflight.ok":              "Состояние: OK",
+	"preflight.degraded":        "Состояние: деградированный режим",
+	"preflight.ytdlp_old":       "yt-dlp {version} старее {min} — обновите: yt-dlp -U",
+	"preflight.ytdlp_missing":   "yt-dlp не найден: {error}",
+	"preflight.no_encoder":      "ffmpeg без кодека {encoder}",
+	"preflight.ffmpeg_missing":  "ffmpeg не найден (слияние видео/аудио и MP3 не будут работать): {error}",
+	"preflight.ffprobe_missing": "ffprobe не найден: {error}",
+
+	"status.unchecked":      "Проверка окружения не запускалась",
+	"status.breaker_open":   "Предохранитель: открыт до {time}",
+	"status.breaker_closed": "Предохранитель: закрыт",
+	"status.bandwidth.one":  "Лимит канала: {kbps} КБ/с на {n} загрузку",
+	"status.bandwidth.few":  "Лимит канала: {kbps} КБ/с на {n} загрузки",
+	"status.bandwidth.many": "Лимит канала: {kbps} КБ/с на {n} загрузок",
+	"status.proxy":          "Прокси {proxy}: ok={ok} fail={fail} score={score}",
+	"status.proxy_paused":   "(пауза до {time})",
+	"status.direct":         "напрямую",
+
+	"error.private":     "Не удалось скачать: видео приватное. Попросите автора открыть доступ или пришлите другую ссылку.",
+	"error.removed":     "Не удалось скачать: видео удалено или недоступно. Проверьте ссылку.",
+	"error.geo":         "Не удалось скачать: видео недоступно в регионе сервера бота.",
+	"error.age":         "Не удалось скачать: у видео возрастное ограничение, без входа в аккаунт YouTube его не получить.",
+	"error.members":     "Не удалось скачать: видео доступно только спонсорам канала.",
+	"error.not_started": "Не удалось скачать: трансляция или премьера ещё не началась. Пришлите ссылку после начала.",
+	"error.rate_limit":  "YouTube временно ограничил запросы. Попробуйте через несколько минут.",
+	"error.bot_check":   "YouTube запросил проверку «я не робот». Попробуйте позже.",
+	"error.network":     "Сетевая ошибка при загрузке. Попробуйте ещё раз чуть позже.",
+	"error.disk_full":   "На сервере закончилось место. Попробуйте позже.",
+	"error.timeout":     "Загрузка заняла слишком много времени и была остановлена. Попробуйте качество пониже или MP3.",
+	"error.postprocess": "Видео скачано, но обработать файл не удалось. Попробуйте другой вариант.",
+	"error.unknown":     "Не удалось скачать видео. Попробуйте ещё раз или выберите другой вариант.",
+	"error.step":        "Шаг обработки {step} не удался.",
+	"error.live":        "Не удалось скачать: это идущая трансляция. Пришлите ссылку ещё раз и выберите, сколько минут записать.",
+
+	"size.gb":           "{n} ГБ",
+	"size.mb":           "{n} МБ",
+	"size.kb":           "{n} КБ",
+	"caption.more.one":  "… ещё {n} глава",
+	"caption.more.few":  "… ещё {n} главы",
+	"caption.more.many": "… ещё {n} глав",
+	"caption.admins":    "Менять шаблон подписи в группе могут только её администраторы.",
+	"caption.current":   "Шаблон подписи ({mode}):\n{template}\n\nПоля: {fields}\n/caption reset — вернуть общий",
+	"caption.invalid":   "Шаблон не подошёл: {err}",
+	"caption.reset":     "Шаблон подписи сброшен.",
+	"caption.saved":     "Шаблон подписи сохранён.",
+
+	"sponsor.current":          "SponsorBlock: {mode}.\nИспользование: /sponsorblock off|remove|mark [категории]\nКатегории: {categories}",
+	"sponsor.bad_mode":         "Режим: off, remove или mark.",
+	"sponsor.unknown_category": "Неизвестная категория {category}. Доступны: {categories}, all",
+	"sponsor.saved":            "SponsorBlock: {mode}.",
+	"sponsor.button":           "SponsorBlock: {mode}",
+	"sponsor.none":             "SponsorBlock: сегментов не найдено",
+	"sponsor.cut":              "Вырезано SponsorBlock: {time}",
+	"sponsor.remove":           "вырезать",
+	"sponsor.mark":             "главы",
+	"sponsor.off":              "выкл",
+
+	"group.change":      "Изменить: /group variants 720,mp3|all, /group default 720|off, /group trigger all|admins",
+	"group.admins":      "Настройки группы меняют только её администраторы.",
+	"group.usage":       "Использование: /group variants|default|trigger значение",
+	"group.unknown":     "Неизвестный вариант {code}. Доступны: {codes}",
+	"group.bad_trigger": "Кто запускает загрузки: all или admins.",
+	"group.bad_setting": "Настройки: variants, default, trigger.",
+	"group.summary":     "Варианты: {variants}\nПо умолчанию: {default}\nЗапускают загрузки: {who}",
+	"group.no_default":  "нет (кнопки)",
+	"group.who_all":     "все участники",
+	"group.who_admins":  "только администраторы",
+
+	"inline.open_bot":    "Открыть в боте",
+	"inline.title":       "Скачать: {label}",
+	"inline.description": "Файл появится в сообщении после загрузки",
+	"inline.loading":     "⏳ Загружаю: {label}",
+	"inline.open_yt":     "Открыть на YouTube",
+	"inline.expired":     "Ссылка устарела. Наберите запрос ещё раз.",
+	"inline.live":        "Трансляции и премьеры доступны только в чате с ботом.",
+	"inline.start_bot":   "Не могу передать файл: вы ещё не запускали бота. Нажмите «Открыть в боте», отправьте /start и повторите запрос.",
+
+	"settings.title":              "Настройки. Нажмите на строку, чтобы изменить:",
+	"settings.variant":            "Качество по умолчанию: {value}",
+	"settings.variant_none":       "не выбрано",
+	"settings.auto":               "Сразу качать без кнопок: {value}",
+	"settings.caption":            "Подписи к файлам: {value}",
+	"settings.subs":               "Субтитры: {value}",
+	"settings.audio":              "Формат аудио: {value}",
+	"settings.lang":               "Язык: {value}",
+	"settings.lang_auto":          "авто",
+	"settings.on":                 "вкл",
+	"settings.off":                "выкл",
+	"settings.auto_needs_variant": "Сначала выберите качество по умолчанию.",
+	"settings.saved":              "Сохранено",
+}
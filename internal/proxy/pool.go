@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategy — способ выбора прокси из пула
+type Strategy string
+
+const (
+	RoundRobin    Strategy = "round-robin"
+	LeastFailures Strategy = "least-failures"
+)
+
+// после стольких неудач подряд прокси уходит в cooldown даже без явного сигнала
+const maxStreak = 3
+
+// размер журнала использования прокси
+const historySize = 200
+
+// Usage — запись о том, какой прокси обслужил задачу
+type Usage struct {
+	Job   string // ID задачи; пусто — служебный запрос (опрос метаданных)
+	URL   string
+	Proxy string
+	OK    bool
+	At    time.Time
+}
+
+// Stat — снимок состояния прокси для логов и отчётов
+type Stat struct {
+	Proxy         string
+	Successes     int
+	Failures      int
+	Streak        int
+	Score         float64
+	CooldownUntil time.Time
+	LastError     string
+}
+
+type entry struct {
+	url           string
+	ok, fail      int
+	streak        int
+	cooldownUntil time.Time
+	lastErr       string
+}
+
+// score — доля успехов со сглаживанием, штраф за серию неудач
+func (e *entry) score() float64 {
+	s := float64(e.ok+1) / float64(e.ok+e.fail+2)
+	return s / float64(1+e.streak)
+}
+
+// Pool — пул прокси с ротацией, оценкой здоровья и cooldown
+
+type Pool struct {
+	mu       sync.Mutex
+	items    []*entry
+	strategy Strategy
+	cooldown time.Duration
+	next     int
+	history  []Usage
+}
+
+func NewPool(urls []string, strategy Strategy, cooldown time.Duration) *Pool {
+	if strategy != LeastFailures {
+		strategy = RoundRobin
+	}
+	if cooldown <= 0 {
+		cooldown = 5 * time.Minute
+	}
+	p := &Pool{strategy: strategy, cooldown: cooldown}
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		p.items = append(p.items, &entry{url: u})
+	}
+	return p
+}
+
+// Len — число прокси в пуле
+func (p *Pool) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.items)
+}
+
+// Pick — выбрать доступный прокси, пропуская exclude и находящиеся в cooldown
+func (p *Pool) Pick(exclude map[string]bool) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var avail []*entry
+	for _, e := range p.items {
+		if exclude[e.url] || now.Before(e.cooldownUntil) {
+			continue
+		}
+		avail = append(avail, e)
+	}
+	if len(avail) == 0 {
+		return "", false
+	}
+
+	if p.strategy == LeastFailures {
+		best := avail[0]
+		for _, e := range avail[1:] {
+			if e.score() > best.score() {
+				best = e
+			}
+		}
+		return best.url, true
+	}
+
+	// round-robin по исходному порядку, начиная с курсора
+	for i := 0; i < len(p.items); i++ {
+		e := p.items[(p.next+i)%len(p.items)]
+		if exclude[e.url] || now.Before(e.cooldownUntil) {
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.items)
+		return e.url, true
+	}
+	return "", false
+}
+
+// Success — отметить успешную загрузку через прокси
+func (p *Pool) Success(proxy string) {
+	p.update(proxy, func(e *entry) {
+		e.ok++
+		e.streak = 0
+		e.lastErr = ""
+	})
+}
+
+// Failure — отметить неудачу; cool=true сразу отправляет прокси в cooldown
+// (rate limit, DNS и т.п.), иначе cooldown наступает после серии неудач
+func (p *Pool) Failure(proxy, reason string, cool bool) {
+	p.update(proxy, func(e *entry) {
+		e.fail++
+		e.streak++
+		e.lastErr = reason
+		if cool || e.streak >= maxStreak {
+			e.cooldownUntil = time.Now().Add(p.cooldown)
+		}
+	})
+}
+
+func (p *Pool) update(proxy string, fn func(e *entry)) {
+	if p == nil || proxy == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.items {
+		if e.url == proxy {
+			fn(e)
+			return
+		}
+	}
+}
+
+// Record — запомнить, какой прокси обслужил задачу (пустой proxy — напрямую)
+func (p *Pool) Record(job, jobURL, proxy string, ok bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.history = append(p.history, Usage{Job: job, URL: jobURL, Proxy: proxy, OK: ok, At: time.Now()})
+	if len(p.history) > historySize {
+		p.history = p.history[len(p.history)-historySize:]
+	}
+}
+
+// History — последние записи использования, от старых к новым
+func (p *Pool) History() []Usage {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Usage, len(p.history))
+	copy(out, p.history)
+	return out
+}
+
+// Stats — состояние прокси, лучшие сверху
+func (p *Pool) Stats() []Stat {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Stat, 0, len(p.items))
+	for _, e := range p.items {
+		out = append(out, Stat{
+			Proxy:         Redact(e.url),
+			Successes:     e.ok,
+			Failures:      e.fail,
+			Streak:        e.streak,
+			Score:         e.score(),
+			CooldownUntil: e.cooldownUntil,
+			LastError:     e.lastErr,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+// Redact — скрыть логин/пароль прокси для логов
+func Redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Scheme + "://***@" + u.Host
+}
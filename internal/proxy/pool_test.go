@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolRoundRobin(t *testing.T) {
+	t.Parallel()
+	p := NewPool([]string{"http://a:1", "http://b:1", "http://a:1", ""}, RoundRobin, time.Minute)
+	if p.Len() != 2 {
+		t.Fatalf("Len() = %d; want 2 (duplicates and blanks dropped)", p.Len())
+	}
+	want := []string{"http://a:1", "http://b:1", "http://a:1"}
+	for i, w := range want {
+		got, ok := p.Pick(nil)
+		if !ok || got != w {
+			t.Fatalf("pick %d = (%q,%v); want %q", i, got, ok, w)
+		}
+	}
+}
+
+func TestPoolCooldownAndExclude(t *testing.T) {
+	t.Parallel()
+	p := NewPool([]string{"http://a:1", "http://b:1"}, RoundRobin, time.Minute)
+
+	p.Failure("http://a:1", "HTTP Error 429", true)
+	for i := 0; i < 3; i++ {
+		if got, _ := p.Pick(nil); got != "http://b:1" {
+			t.Fatalf("pick %d = %q; want only b while a cools down", i, got)
+		}
+	}
+	if got, ok := p.Pick(map[string]bool{"http://b:1": true}); ok {
+		t.Fatalf("Pick with all excluded/cooling = %q; want none", got)
+	}
+
+	// серия обычных неудач тоже уводит в cooldown
+	for i := 0; i < maxStreak; i++ {
+		p.Failure("http://b:1", "exit status 1", false)
+	}
+	if got, ok := p.Pick(nil); ok {
+		t.Fatalf("Pick after streak = %q; want none", got)
+	}
+}
+
+func TestPoolLeastFailures(t *testing.T) {
+	t.Parallel()
+	p := NewPool([]string{"http://a:1", "http://b:1", "http://c:1"}, LeastFailures, time.Minute)
+	p.Failure("http://a:1", "x", false)
+	p.Success("http://b:1")
+	p.Success("http://b:1")
+	p.Failure("http://c:1", "x", false)
+	p.Failure("http://c:1", "x", false)
+
+	for i := 0; i < 3; i++ {
+		if got, _ := p.Pick(nil); got != "http://b:1" {
+			t.Fatalf("pick %d = %q; want healthiest b", i, got)
+		}
+	}
+	if st := p.Stats(); st[0].Proxy != "http://b:1" {
+		t.Fatalf("Stats()[0] = %q; want b first", st[0].Proxy)
+	}
+}
+
+func TestPoolHistoryAndRedact(t *testing.T) {
+	t.Parallel()
+	p := NewPool([]string{"http://user:secret@h:8080"}, RoundRobin, time.Minute)
+	p.Record("job1", "https://youtu.be/x", "http://user:secret@h:8080", true)
+	p.Record("", "https://youtu.be/y", "", false)
+	h := p.History()
+	if len(h) != 2 || h[0].Job != "job1" || h[0].URL != "https://youtu.be/x" || !h[0].OK || h[1].Proxy != "" {
+		t.Fatalf("History() = %+v", h)
+	}
+	if got := Redact("http://user:secret@h:8080"); got != "http://***@h:8080" {
+		t.Fatalf("Redact = %q", got)
+	}
+	if got := p.Stats()[0].Proxy; got != "http://***@h:8080" {
+		t.Fatalf("Stats proxy not redacted: %q", got)
+	}
+}
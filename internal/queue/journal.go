@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxResumes — после стольких перезапусков задача считается причиной падения
+// (OOM, kill во время ffmpeg) и из журнала удаляется
+const MaxResumes = 3
+
+// Journal — незавершённые задачи на диске (JSON), чтобы после перезапуска
+// продолжить их с тем же ID, а значит и с той же рабочей директорией и .part-файлами
+
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	jobs map[string]Job
+}
+
+// OpenJournal — открыть журнал; отсутствующий файл — пустой журнал
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path, jobs: make(map[string]Job)}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Job
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	for _, job := range list {
+		j.jobs[job.ID] = job
+	}
+	return j, nil
+}
+
+// Pending — незавершённые задачи в порядке поступления
+func (j *Journal) Pending() []Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]Job, 0, len(j.jobs))
+	for _, job := range j.jobs {
+		out = append(out, job)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].RequestedAt != out[b].RequestedAt {
+			return out[a].RequestedAt < out[b].RequestedAt
+		}
+		return out[a].ID < out[b].ID
+	})
+	return out
+}
+
+// Resume — задачи для продолжения после перезапуска; счётчик Resumes сохраняется
+// до постановки в очередь, поэтому падение процесса на задаче тоже засчитывается.
+// Задачи, исчерпавшие MaxResumes или старше ttl (частичные файлы уже удалены
+// очисткой), убираются из журнала и возвращаются в dropped
+func (j *Journal) Resume(ttl time.Duration) (resume, dropped []Job, err error) {
+	pending := j.Pending()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, job := range pending {
+		stale := ttl > 0 && job.RequestedAt > 0 && now.Sub(time.Unix(job.RequestedAt, 0)) > ttl
+		if job.Resumes >= MaxResumes || stale {
+			delete(j.jobs, job.ID)
+			dropped = append(dropped, job)
+			continue
+		}
+		job.Resumes++
+		j.jobs[job.ID] = job
+		resume = append(resume, job)
+	}
+	if len(pending) == 0 {
+		return nil, nil, nil
+	}
+	return resume, dropped, j.saveLocked()
+}
+
+// Put — записать/обновить задачу
+func (j *Journal) Put(job Job) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jobs[job.ID] = job
+	return j.saveLocked()
+}
+
+// Done — убрать задачу, если в журнале та же её версия; отложенная задача
+// (Attempts увеличен) уже перезаписана новой версией и остаётся
+func (j *Journal) Done(job Job) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cur, ok := j.jobs[job.ID]
+	if !ok || cur.Attempts != job.Attempts {
+		return nil
+	}
+	delete(j.jobs, job.ID)
+	return j.saveLocked()
+}
+
+// saveLocked — атомарная запись через временный файл
+func (j *Journal) saveLocked() error {
+	list := make([]Job, 0, len(j.jobs))
+	for _, job := range j.jobs {
+		list = append(list, job)
+	}
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalSurvivesReopen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	a := Job{ID: "a", ChatID: 1, URL: "https://youtu.be/aaaaaaa", Variant: Variant("video720"), RequestedAt: 2}
+	b := Job{ID: "b", ChatID: 2, URL: "https://youtu.be/bbbbbbb", Variant: Variant("audioMp3"), RequestedAt: 1}
+	if err := j.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	j2, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	p := j2.Pending()
+	if len(p) != 2 || p[0].ID != "b" || p[1].ID != "a" || p[1].Variant != Variant("video720") {
+		t.Fatalf("Pending() = %+v; want b then a", p)
+	}
+}
+
+func TestJournalDoneKeepsDelayedVersion(t *testing.T) {
+	t.Parallel()
+	j, _ := OpenJournal(filepath.Join(t.TempDir(), "jobs.json"))
+	job := Job{ID: "x", ChatID: 1}
+	_ = j.Put(job)
+
+	// задачу отложили: новая версия с Attempts+1 уже в журнале
+	delayed := job
+	delayed.Attempts++
+	_ = j.Put(delayed)
+
+	// завершение старой версии не должно удалить отложенную
+	_ = j.Done(job)
+	if p := j.Pending(); len(p) != 1 || p[0].Attempts != 1 {
+		t.Fatalf("Pending() after stale Done = %+v", p)
+	}
+	_ = j.Done(delayed)
+	if p := j.Pending(); len(p) != 0 {
+		t.Fatalf("Pending() after Done = %+v; want empty", p)
+	}
+}
+
+func TestJournalResumeGivesUp(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	j, _ := OpenJournal(path)
+	now := time.Now().Unix()
+	_ = j.Put(Job{ID: "crash", ChatID: 1, RequestedAt: now})
+	_ = j.Put(Job{ID: "old", ChatID: 2, RequestedAt: now - 48*3600})
+
+	// каждый перезапуск увеличивает счётчик на диске; старая задача сразу отбрасывается
+	for i := 1; i <= MaxResumes; i++ {
+		j, _ = OpenJournal(path)
+		resume, dropped, err := j.Resume(24 * time.Hour)
+		if err != nil || len(resume) != 1 || resume[0].ID != "crash" || resume[0].Resumes != i {
+			t.Fatalf("restart %d: resume = %+v, err = %v", i, resume, err)
+		}
+		if i == 1 && (len(dropped) != 1 || dropped[0].ID != "old") {
+			t.Fatalf("stale job not dropped: %+v", dropped)
+		}
+	}
+
+	// задача так и не завершилась — больше не поднимается
+	j, _ = OpenJournal(path)
+	resume, dropped, _ := j.Resume(24 * time.Hour)
+	if len(resume) != 0 || len(dropped) != 1 || dropped[0].ID != "crash" {
+		t.Fatalf("after MaxResumes: resume = %+v, dropped = %+v", resume, dropped)
+	}
+	if p := j.Pending(); len(p) != 0 {
+		t.Fatalf("Pending() = %+v; want empty", p)
+	}
+}
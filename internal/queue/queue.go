@@ -2,28 +2,67 @@ package queue
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Variant — выбранный пользователем вариант загрузки
+// Variant — выбранный пользователем вариант загрузки: ID пресета из реестра
 
 type Variant string
 
+// VarLive — запись трансляции: не пресет, формат и длительность задаются LiveMinutes
+const VarLive Variant = "live"
+
+// режимы SponsorBlock: вырезать сегменты или только отметить главами
+const (
+    SponsorOff    = ""
+    SponsorRemove = "remove"
+    SponsorMark   = "mark"
+)
+
+// форматы аудио-вариантов: оба Telegram показывает как аудио
 const (
-    VarVideo360 Variant = "video360"
-    VarVideo720 Variant = "video720"
-    VarVideo1080 Variant = "video1080"
-    VarVideo1440 Variant = "video1440"
-    VarAudioMP3 Variant = "audioMp3"
+    AudioMP3 = "mp3"
+    AudioM4A = "m4a"
 )
 
+// SponsorBlock — параметры SponsorBlock для задачи
+type SponsorBlock struct {
+	Mode       string   `json:"mode,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
 // Job — задача на загрузку
 
 type Job struct {
+	ID          string
 	ChatID      int64
 	URL         string
 	Variant     Variant
 	RequestedAt int64
 	Attempts    int
+	// сколько раз задачу поднимали из журнала после перезапуска бота
+	Resumes int
+	// запись трансляции: длительность в минутах (0 — обычное видео) и режим «с начала»
+	LiveMinutes   int
+	LiveFromStart bool
+	SponsorBlock  SponsorBlock
+	// инлайн-режим: файл подставляется в это сообщение вместо отправки в ChatID
+	InlineMessageID string
+	// группа: ответ на сообщение со ссылкой и тема форума (message_thread_id) — файл
+	// придёт в ту же тему, даже если сообщение со ссылкой удалили
+	ReplyTo  int
+	ThreadID int
+	// язык сообщений пользователя о задаче
+	Lang string
+	// настройки пользователя: язык субтитров (видео), формат аудио (пусто — mp3), файл без подписи
+	Subtitles   string
+	AudioFormat string
+	NoCaption   bool
 }
 
 // Queue — простая очередь с воркерами
@@ -31,6 +70,10 @@ type Job struct {
 type Queue struct {
 	ch      chan Job
 	workers int
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+	journal     *Journal
 }
 
 func NewQueue(capacity, workers int) *Queue {
@@ -39,7 +82,97 @@ func NewQueue(capacity, workers int) *Queue {
 	return &Queue{ch: make(chan Job, capacity), workers: workers}
 }
 
-func (q *Queue) Enqueue(j Job) { q.ch <- j }
+var jobSeq atomic.Int64
+
+// NewJobID — уникальный в пределах процесса и между перезапусками ID задачи
+func NewJobID() string {
+	return fmt.Sprintf("%x-%d", time.Now().UnixNano(), jobSeq.Add(1))
+}
+
+// UseJournal — сохранять задачи на диск до их завершения
+func (q *Queue) UseJournal(j *Journal) { q.journal = j }
+
+func (q *Queue) Enqueue(j Job) {
+	j = q.prepare(j)
+	q.ch <- j
+}
+
+// TryEnqueue — постановка без блокировки; false, если буфер заполнен
+func (q *Queue) TryEnqueue(j Job) bool {
+	j = q.prepare(j)
+	select {
+	case q.ch <- j:
+		return true
+	default:
+		q.done(j)
+		return false
+	}
+}
+
+// prepare — ID задачи и запись в журнал
+func (q *Queue) prepare(j Job) Job {
+	if j.ID == "" { j.ID = NewJobID() }
+	if q.journal != nil {
+		if err := q.journal.Put(j); err != nil {
+			log.Printf("[queue] journal put failed: %v", err)
+		}
+	}
+	return j
+}
+
+func (q *Queue) done(j Job) {
+	if q.journal == nil { return }
+	if err := q.journal.Done(j); err != nil {
+		log.Printf("[queue] journal done failed: %v", err)
+	}
+}
+
+// Len — число задач, ожидающих в буфере
+func (q *Queue) Len() int { return len(q.ch) }
+
+// Pause — воркеры не начинают новые задачи до указанного времени
+func (q *Queue) Pause(until time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if until.After(q.pausedUntil) {
+		q.pausedUntil = until
+	}
+}
+
+// PausedUntil — время окончания паузы (нулевое или в прошлом — не на паузе)
+func (q *Queue) PausedUntil() time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pausedUntil
+}
+
+// waitPause — ждать окончания паузы; false, если контекст отменён
+func (q *Queue) waitPause(ctx context.Context) bool {
+	for {
+		d := time.Until(q.PausedUntil())
+		if d <= 0 {
+			return true
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false
+		case <-t.C:
+		}
+	}
+}
+
+// run — паника в обработчике задачи не убивает воркера; задача считается завершённой,
+// иначе после перезапуска она падала бы снова
+func run(ctx context.Context, worker func(ctx context.Context, job Job), j Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[queue] worker panic: job=%s chat=%d: %v\n%s", j.ID, j.ChatID, r, debug.Stack())
+		}
+	}()
+	worker(ctx, j)
+}
 
 func (q *Queue) Start(ctx context.Context, worker func(ctx context.Context, job Job)) {
 	for i := 0; i < q.workers; i++ {
@@ -49,7 +182,14 @@ func (q *Queue) Start(ctx context.Context, worker func(ctx context.Context, job
 				case <-ctx.Done():
 					return
 				case j := <-q.ch:
-					worker(ctx, j)
+					if !q.waitPause(ctx) {
+						return
+					}
+					run(ctx, worker, j)
+					// прерванная остановкой задача остаётся в журнале для докачки
+					if ctx.Err() == nil {
+						q.done(j)
+					}
 				}
 			}
 		}()
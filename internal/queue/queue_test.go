@@ -0,0 +1,33 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkerSurvivesPanic(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewQueue(10, 1)
+	done := make(chan string, 2)
+	q.Start(ctx, func(_ context.Context, j Job) {
+		if j.URL == "bad" {
+			panic("boom")
+		}
+		done <- j.URL
+	})
+	q.Enqueue(Job{URL: "bad"})
+	q.Enqueue(Job{URL: "good"})
+
+	select {
+	case u := <-done:
+		if u != "good" {
+			t.Fatalf("got %q", u)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker died after panic")
+	}
+}
@@ -0,0 +1,17 @@
+package sponsorblock
+
+// Categories — категории, которые понимают API и yt-dlp
+var Categories = []string{"sponsor", "intro", "outro", "selfpromo", "preview", "filler", "interaction", "music_offtopic"}
+
+// ValidCategory — известная категория или all
+func ValidCategory(c string) bool {
+	if c == "all" {
+		return true
+	}
+	for _, k := range Categories {
+		if k == c {
+			return true
+		}
+	}
+	return false
+}
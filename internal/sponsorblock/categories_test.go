@@ -0,0 +1,12 @@
+package sponsorblock
+
+import "testing"
+
+func TestValidCategory(t *testing.T) {
+	t.Parallel()
+	for c, want := range map[string]bool{"sponsor": true, "music_offtopic": true, "all": true, "ads": false, "": false} {
+		if got := ValidCategory(c); got != want {
+			t.Errorf("ValidCategory(%q) = %v; want %v", c, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CachedFile — уже загруженный в Telegram файл: повторно отправляется по file_id без скачивания.
+// Подпись не хранится: шаблон и язык у каждого свои, её собирают из этих сведений
+type CachedFile struct {
+	FileID   string    `json:"file_id"`
+	Send     string    `json:"send"` // video|audio|document
+	Title    string    `json:"title,omitempty"`
+	Uploader string    `json:"uploader,omitempty"`
+	Duration float64   `json:"duration,omitempty"`
+	Width    int       `json:"width,omitempty"`
+	Height   int       `json:"height,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Chapters []Chapter `json:"chapters,omitempty"`
+
+	SponsorRemoved bool    `json:"sponsor_removed,omitempty"`
+	SponsorCut     float64 `json:"sponsor_cut,omitempty"`
+}
+
+// Chapter — глава ролика для подписи
+type Chapter struct {
+	Start float64 `json:"start"`
+	Title string  `json:"title"`
+}
+
+// FileCache — file_id по ключу «ролик:пресет» в памяти с сохранением в JSON (путь пустой — только память)
+type FileCache struct {
+	mu   sync.Mutex
+	path string
+	data map[string]CachedFile
+}
+
+func NewFileCache() *FileCache { return &FileCache{data: make(map[string]CachedFile)} }
+
+// OpenFileCache — загрузить кэш из файла; отсутствующий файл — пустой кэш
+func OpenFileCache(path string) (*FileCache, error) {
+	c := NewFileCache()
+	c.path = path
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.data); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *FileCache) Get(key string) (CachedFile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.data[key]
+	return f, ok
+}
+
+// Put — запомнить файл и сохранить
+func (c *FileCache) Put(key string, f CachedFile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = f
+	if c.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(c.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
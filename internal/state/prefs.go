@@ -0,0 +1,108 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"youtube-bot-simple/internal/queue"
+)
+
+// UserPrefs — персональные настройки пользователя; настройки чата (шаблон подписи)
+// хранятся так же, по ID чата — у личного чата он совпадает с ID пользователя
+type UserPrefs struct {
+	SponsorBlock queue.SponsorBlock `json:"sponsorblock"`
+	Caption      string             `json:"caption,omitempty"`
+	Group        *GroupPrefs        `json:"group,omitempty"`
+	Language     string             `json:"language,omitempty"` // выбран через /language; пусто — по языку Telegram
+	// /settings: пресет по умолчанию и загрузка без клавиатуры, подписи, субтитры, формат аудио
+	Variant     string `json:"variant,omitempty"`
+	Auto        bool   `json:"auto,omitempty"`
+	NoCaption   bool   `json:"no_caption,omitempty"`
+	Subtitles   string `json:"subtitles,omitempty"`
+	AudioFormat string `json:"audio_format,omitempty"`
+}
+
+// GroupPrefs — настройки группы, задаются её администраторами
+type GroupPrefs struct {
+	Variants   []string `json:"variants,omitempty"` // ID разрешённых пресетов; пусто — все
+	Default    string   `json:"default,omitempty"`  // ID пресета: ссылка качается сразу, без кнопок
+	AdminsOnly bool     `json:"admins_only,omitempty"`
+}
+
+// Prefs — настройки пользователей в памяти с сохранением в JSON (путь пустой — только память)
+
+type Prefs struct {
+	mu   sync.Mutex
+	path string
+	data map[int64]UserPrefs
+}
+
+func NewPrefs() *Prefs { return &Prefs{data: make(map[int64]UserPrefs)} }
+
+// OpenPrefs — загрузить настройки из файла; отсутствующий файл — пустые настройки
+func OpenPrefs(path string) (*Prefs, error) {
+	p := NewPrefs()
+	p.path = path
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// ключи JSON — строки, поэтому map[string]
+	var raw map[string]UserPrefs
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	for k, v := range raw {
+		id, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			continue
+		}
+		p.data[id] = v
+	}
+	return p, nil
+}
+
+// Get — настройки пользователя (нулевые, если не задавались)
+func (p *Prefs) Get(userID int64) UserPrefs {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.data[userID]
+}
+
+// Update — изменить настройки пользователя и сохранить
+func (p *Prefs) Update(userID int64, fn func(u *UserPrefs)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u := p.data[userID]
+	fn(&u)
+	p.data[userID] = u
+	return p.saveLocked()
+}
+
+func (p *Prefs) saveLocked() error {
+	if p.path == "" {
+		return nil
+	}
+	raw := make(map[string]UserPrefs, len(p.data))
+	for k, v := range p.data {
+		raw[strconv.FormatInt(k, 10)] = v
+	}
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
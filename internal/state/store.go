@@ -5,13 +5,16 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"youtube-bot-simple/internal/queue"
 )
 
 // Payload — полезная нагрузка, которую храним по токену
 // кратко и по делу
 
 type Payload struct {
-	URL string
+	URL          string
+	SponsorBlock queue.SponsorBlock
 }
 
 type entry struct {
@@ -3,18 +3,39 @@ package telegram
 import (
     tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
     "context"
-    "youtube-bot-simple/internal/queue"
+    "youtube-bot-simple/internal/downloader"
+    "youtube-bot-simple/internal/i18n"
 )
 
 // Sender — минимальный интерфейс Telegram API для тестирования
 type Sender interface {
     Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
     Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
-    GetUpdatesChan(u tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
 }
 
+// DownloadRequest / DownloadResult — запрос к загрузчику и готовый файл со всеми сведениями о нём
+type (
+    DownloadRequest = downloader.DownloadRequest
+    DownloadResult  = downloader.DownloadResult
+)
+
 // Downloader — интерфейс загрузчика медиа
 type Downloader interface {
-    Download(ctx context.Context, url string, v queue.Variant) (string, int64, string, error)
+    Download(ctx context.Context, req DownloadRequest) (*DownloadResult, error)
+}
+
+// Prober — опрос ссылки до показа кнопок (live/премьера); опционально
+type Prober interface {
+    Probe(ctx context.Context, url string) (*downloader.Info, error)
 }
 
+// StatusReporter — сводка состояния загрузчика для админской команды /status на языке админа; опционально
+type StatusReporter interface {
+    StatusText(l i18n.Localizer) string
+}
+
+// RawUploader — прямой вызов метода Bot API с файлами (*tgbotapi.BotAPI) для полей,
+// которых нет в конфигах библиотеки (width/height у sendVideo); опционально
+type RawUploader interface {
+    UploadFiles(endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (*tgbotapi.APIResponse, error)
+}
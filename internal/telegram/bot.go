@@ -2,14 +2,18 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/downloader"
 	"youtube-bot-simple/internal/files"
+	"youtube-bot-simple/internal/i18n"
 	"youtube-bot-simple/internal/queue"
 	"youtube-bot-simple/internal/state"
 
@@ -24,96 +28,313 @@ type Bot struct {
 	store *state.Store
 	q     *queue.Queue
 	DL    Downloader
+
+	presets *config.Presets
+	prefs   *state.Prefs
+	files   *state.FileCache
+	// имя бота без @: команды вида /cmd@bot и упоминания в группах
+	username string
+	texts    *i18n.Bundle
+	commands *Commands
+	router   *Router
+	threads  *threads // темы форума из сырых обновлений
 }
 
 func NewBot(api Sender, cfg *config.Config, st *state.Store, q *queue.Queue, dl Downloader) *Bot {
-	return &Bot{api: api, cfg: cfg, store: st, q: q, DL: dl}
+	b := &Bot{api: api, cfg: cfg, store: st, q: q, DL: dl, presets: cfg.PresetRegistry(), prefs: state.NewPrefs(), files: state.NewFileCache(), texts: i18n.Default(), threads: newThreads()}
+	b.commands = b.commandList()
+	b.router = b.routes()
+	return b
 }
 
+// Start — приём обновлений: long polling или webhook (WEBHOOK_URL), обработчики общие
 func (b *Bot) Start(ctx context.Context) error {
-	updCfg := tgbotapi.NewUpdate(0)
-	updCfg.Timeout = 30
-
-	updates := b.api.GetUpdatesChan(updCfg)
+	var updates tgbotapi.UpdatesChannel
+	var srvErr <-chan error
+	if b.cfg.WebhookURL != "" {
+		var stop func()
+		var err error
+		if updates, srvErr, stop, err = b.startWebhook(); err != nil {
+			return err
+		}
+		defer stop()
+	} else {
+		updates = b.pollUpdates(ctx)
+	}
 	log.Printf("[bot] started; downloads at: %s", b.cfg.DownloadDir)
+	b.publishCommands()
 
+	// медленная отправка одному пользователю не задерживает остальных
+	d := newDispatcher(b.cfg.UpdateWorkers)
+	defer d.stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case err := <-srvErr:
+			return err
 		case u := <-updates:
-			if u.Message != nil {
-				b.handleMessage(ctx, u.Message)
-			}
-			if u.CallbackQuery != nil {
-				b.handleCallback(ctx, u.CallbackQuery)
-			}
+			d.submit(updateChat(u), func() { b.dispatch(ctx, u) })
 		}
 	}
 }
 
+// routes — команды, кнопки и текст; общие проверки — middleware, а не условия в обработчиках
+func (b *Bot) routes() *Router {
+	r := NewRouter()
+	r.Use(logSlow(slowHandler), b.groupFilter)
+
+	for _, c := range b.commands.All() {
+		r.Command(c.Name, with(c.Handler, b.inScope(c)))
+	}
+
+	r.Text(func(u *Update) bool { return extractYouTubeURL(u.Message.Text) != "" }, with(b.onLink, b.requireTrigger))
+	r.Fallback(with(b.onNotLink, b.requireTrigger))
+
+	// переключатель SponsorBlock не запускает загрузку; остальные кнопки — выбор варианта
+	r.Callback("sb", with(b.onSponsorToggle, b.requireTrigger))
+	r.Callback("set", b.onSettings)
+	r.Callback("", with(b.onVariant, b.requireTrigger))
+
+	r.Inline(func(u *Update) { b.handleInlineQuery(u.InlineQuery) })
+	r.Chosen(func(u *Update) { b.handleChosenInline(u.Ctx, u.ChosenInlineResult) })
+	return r
+}
+
+func (b *Bot) dispatch(ctx context.Context, u tgbotapi.Update) {
+	b.router.Handle(ctx, u, b.threads.take(u.UpdateID))
+}
+
 func (b *Bot) handleMessage(ctx context.Context, m *tgbotapi.Message) {
-	text := strings.TrimSpace(m.Text)
-	if text == "" {
-		return
+	b.dispatch(ctx, tgbotapi.Update{Message: m})
+}
+
+func (b *Bot) handleCallback(ctx context.Context, c *tgbotapi.CallbackQuery) {
+	b.dispatch(ctx, tgbotapi.Update{CallbackQuery: c})
+}
+
+// message — обработчик, которому нужно только сообщение
+func message(h func(m *tgbotapi.Message)) HandlerFunc {
+	return func(u *Update) { h(u.Message) }
+}
+
+// replyAnchor — в группе отвечаем на сообщение: так ответ попадает в ту же тему форума
+func replyAnchor(m *tgbotapi.Message) int {
+	if isGroup(m.Chat) {
+		return m.MessageID
 	}
+	return 0
+}
 
-	switch {
-	case strings.HasPrefix(text, "/start"):
-		b.reply(m.Chat.ID, "Привет! Пришлите ссылку на YouTube, затем выберите вариант (360p/720p/1080p/1440p/MP3).", 0)
-		return
-	case strings.HasPrefix(text, "/help"):
-		b.reply(m.Chat.ID, "Скидывайте ссылку на видео YouTube или Shorts. После выбора варианта бот скачает и пришлёт файл. Ограничение по размеру ~50 МБ.", 0)
+func (b *Bot) onStart(u *Update) {
+	m := u.Message
+	b.reply(m.Chat.ID, b.loc(m.From).T("start"), replyAnchor(m))
+}
+
+func (b *Bot) onHelp(u *Update) {
+	m := u.Message
+	admin := m.From != nil && b.cfg.IsAdmin(m.From.ID)
+	b.reply(m.Chat.ID, b.helpText(b.loc(m.From), isGroup(m.Chat), admin), replyAnchor(m))
+}
+
+func (b *Bot) onStatus(u *Update) {
+	m := u.Message
+	b.reply(m.Chat.ID, b.statusText(b.loc(m.From)), replyAnchor(m))
+}
+
+// onNotLink — текст без ссылки (в группе сюда доходят только упоминания бота)
+func (b *Bot) onNotLink(u *Update) {
+	b.reply(u.Message.Chat.ID, b.loc(u.Message.From).T("link.invalid"), u.Message.MessageID)
+}
+
+// onLink — ссылка: клавиатура вариантов сразу, опрос метаданных (трансляция, премьера) — после неё
+func (b *Bot) onLink(u *Update) {
+	m := u.Message
+	l := b.loc(m.From)
+	url := extractYouTubeURL(m.Text)
+
+	token := state.GenerateToken(12)
+	payload := state.Payload{URL: url, SponsorBlock: b.userSponsor(m.From)}
+	b.store.Put(token, payload, 15*time.Minute)
+
+	// качество по умолчанию у группы или автозагрузка из /settings — тогда без кнопок,
+	// но трансляцию обычной задачей не поставить: сначала дожидаемся опроса
+	p, ok := b.groupDefault(m.Chat.ID)
+	ok = ok && isGroup(m.Chat)
+	if !ok {
+		p, ok = b.autoPreset(m.From, m.Chat.ID)
+	}
+	if ok {
+		b.afterProbe(u.Ctx, m.Chat.ID, url, func(info *downloader.Info) {
+			if info != nil && (info.Live() || info.Upcoming()) {
+				b.sendLivePrompt(m, l, token, info)
+				return
+			}
+			job := queue.Job{ChatID: m.Chat.ID, URL: url, RequestedAt: time.Now().Unix(), SponsorBlock: payload.SponsorBlock, Lang: l.Lang}
+			if isGroup(m.Chat) {
+				job.ReplyTo, job.ThreadID = m.MessageID, u.Thread
+			}
+			b.applyPrefs(&job, m.From, p)
+			b.q.Enqueue(job)
+			b.reply(m.Chat.ID, l.T("queued", "label", p.LabelIn(l.Lang)), m.MessageID)
+		})
 		return
 	}
 
-	url := extractYouTubeURL(text)
-	if url == "" {
-		b.reply(m.Chat.ID, "Похоже, это не ссылка на YouTube. Отправьте ссылку вида https://youtu.be/... или https://youtube.com/...", m.MessageID)
+	kb := buildKeyboard(l, token, payload.SponsorBlock, b.chatPresets(m.Chat.ID), b.defaultVariant(m.From))
+	msg := tgbotapi.NewMessage(m.Chat.ID, l.T("choose"))
+	msg.ReplyToMessageID = m.MessageID
+	msg.ReplyMarkup = kb
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		log.Printf("[bot] send keyboard failed: %v", err)
 		return
 	}
+	b.afterProbe(u.Ctx, m.Chat.ID, url, func(info *downloader.Info) {
+		b.refineKeyboard(m.Chat.ID, sent.MessageID, l, token, kb, info)
+	})
+}
 
-	token := state.GenerateToken(12)
-	b.store.Put(token, state.Payload{URL: url}, 15*time.Minute)
+// afterProbe — опрос ссылки в фоне, чтобы ответ не ждал yt-dlp; без Prober — сразу с nil
+func (b *Bot) afterProbe(ctx context.Context, chatID int64, url string, fn func(info *downloader.Info)) {
+	if _, ok := b.DL.(Prober); !ok {
+		fn(nil)
+		return
+	}
+	go safeCall(chatID, func() { fn(b.probe(ctx, url)) })
+}
 
-	kb := buildKeyboard(token)
-	msg := tgbotapi.NewMessage(m.Chat.ID, "Выберите вариант загрузки:")
+// sendLivePrompt — вместо задачи: кнопки записи трансляции или сообщение о премьере
+func (b *Bot) sendLivePrompt(m *tgbotapi.Message, l i18n.Localizer, token string, info *downloader.Info) {
+	if info.Upcoming() {
+		b.store.Delete(token)
+		b.reply(m.Chat.ID, upcomingText(l, info), m.MessageID)
+		return
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, l.T("live.prompt"))
 	msg.ReplyToMessageID = m.MessageID
-	msg.ReplyMarkup = kb
+	msg.ReplyMarkup = buildLiveKeyboard(l, token, b.cfg.LiveMaxMinutes)
 	if _, err := b.api.Send(msg); err != nil {
-		log.Printf("[bot] send keyboard failed: %v", err)
+		log.Printf("[bot] send live keyboard failed: %v", err)
 	}
 }
 
-func (b *Bot) handleCallback(ctx context.Context, c *tgbotapi.CallbackQuery) {
+// refineKeyboard — уже показанная клавиатура по итогам опроса: запись трансляции,
+// отказ для премьеры, пояснение для прошедшего эфира; обычное видео не трогаем
+func (b *Bot) refineKeyboard(chatID int64, msgID int, l i18n.Localizer, token string, kb tgbotapi.InlineKeyboardMarkup, info *downloader.Info) {
+	if info == nil {
+		return
+	}
+	var edit tgbotapi.Chattable
+	switch {
+	case info.Upcoming():
+		// без клавиатуры: кнопки премьеры всё равно ничего не скачают
+		b.store.Delete(token)
+		edit = tgbotapi.NewEditMessageText(chatID, msgID, upcomingText(l, info))
+	case info.Live():
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, l.T("live.prompt"), buildLiveKeyboard(l, token, b.cfg.LiveMaxMinutes))
+	case info.Processing():
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, l.T("live.processing"), kb)
+	case info.Ended() && info.Duration > 0:
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, l.N("live.ended", int(info.Duration/60+0.5)), kb)
+	default:
+		return
+	}
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("[bot] edit keyboard after probe failed: %v", err)
+	}
+}
+
+func (b *Bot) onSponsorToggle(u *Update) {
+	c := u.CallbackQuery
+	if token, _ := parseCallbackData(c.Data); token != "" {
+		b.toggleSponsor(c, token)
+	}
+}
+
+// onVariant — кнопка варианта: задача в очередь
+func (b *Bot) onVariant(u *Update) {
+	c := u.CallbackQuery
+	l := b.loc(c.From)
 	// ответ на callback
-	callback := tgbotapi.NewCallback(c.ID, "Начинаю загрузку…")
+	callback := tgbotapi.NewCallback(c.ID, l.T("button.starting"))
 	_, _ = b.api.Request(callback)
 
 	token, variant := parseCallbackData(c.Data)
 	if token == "" || variant == "" {
-		b.reply(c.Message.Chat.ID, "Кнопка некорректна. Пришлите ссылку ещё раз.", c.Message.MessageID)
+		b.reply(c.Message.Chat.ID, l.T("button.invalid"), c.Message.MessageID)
 		return
 	}
 
 	payload, ok := b.store.Get(token)
 	if !ok {
-		b.reply(c.Message.Chat.ID, "Кнопка устарела. Пришлите ссылку ещё раз.", c.Message.MessageID)
+		b.reply(c.Message.Chat.ID, l.T("button.expired"), c.Message.MessageID)
 		return
 	}
 
 	// ставим задачу в очередь
-	v := toVariant(variant)
-	job := queue.Job{ChatID: c.Message.Chat.ID, URL: payload.URL, Variant: v, RequestedAt: time.Now().Unix()}
+	job := queue.Job{ChatID: c.Message.Chat.ID, URL: payload.URL, RequestedAt: time.Now().Unix(), SponsorBlock: payload.SponsorBlock, Lang: l.Lang}
+	if isGroup(c.Message.Chat) {
+		// клавиатура — ответ на ссылку; файл отправим ответом туда же
+		job.ReplyTo, job.ThreadID = c.Message.MessageID, u.Thread
+		if c.Message.ReplyToMessage != nil {
+			job.ReplyTo = c.Message.ReplyToMessage.MessageID
+		}
+	}
+	if minutes, fromStart, ok := parseLiveVariant(variant, b.cfg.LiveMaxMinutes); ok {
+		job.Variant = queue.VarLive
+		job.LiveMinutes, job.LiveFromStart = minutes, fromStart
+		job.SponsorBlock = queue.SponsorBlock{}
+	} else if p, ok := b.chatPresets(c.Message.Chat.ID).ByCode(variant); ok {
+		b.applyPrefs(&job, c.From, p)
+	} else {
+		// клавиатура осталась от прежнего набора пресетов
+		b.reply(c.Message.Chat.ID, l.T("button.gone"), c.Message.MessageID)
+		return
+	}
 	b.q.Enqueue(job)
 
-	b.reply(c.Message.Chat.ID, fmt.Sprintf("Задача поставлена в очередь: %s", humanVariant(v)), c.Message.MessageID)
+	if job.LiveMinutes > 0 {
+		b.reply(c.Message.Chat.ID, l.T("live.queued", "what", humanLive(l, job)), c.Message.MessageID)
+		return
+	}
+	b.reply(c.Message.Chat.ID, l.T("queued", "label", presetLabel(l, b.presets, job.Variant)), c.Message.MessageID)
 }
 
-func (b *Bot) reply(chatID int64, text string, replyTo int) {
+// probe — метаданные ссылки, если загрузчик это умеет; ошибка не мешает показать кнопки
+func (b *Bot) probe(ctx context.Context, url string) *downloader.Info {
+	p, ok := b.DL.(Prober)
+	if !ok {
+		return nil
+	}
+	info, err := p.Probe(ctx, url)
+	if err != nil {
+		log.Printf("[bot] probe failed: %v", err)
+		return nil
+	}
+	return info
+}
+
+func (b *Bot) reply(chatID int64, text string, replyTo int) { b.replyIn(chatID, 0, text, replyTo) }
+
+// replyIn — ответ в тему форума: message_thread_id уходит только прямым вызовом
+func (b *Bot) replyIn(chatID int64, thread int, text string, replyTo int) {
+	if up, ok := b.api.(RawUploader); ok && thread != 0 {
+		params := tgbotapi.Params{}
+		_ = params.AddFirstValid("chat_id", chatID)
+		params.AddNonZero("message_thread_id", thread)
+		params["text"] = text
+		params.AddNonZero("reply_to_message_id", replyTo)
+		params.AddBool("allow_sending_without_reply", replyTo > 0)
+		if _, err := up.UploadFiles("sendMessage", params, nil); err != nil {
+			log.Printf("[bot] send message failed: %v", err)
+		}
+		return
+	}
 	msg := tgbotapi.NewMessage(chatID, text)
 	if replyTo > 0 {
 		msg.ReplyToMessageID = replyTo
+		msg.AllowSendingWithoutReply = true
 	}
 	if _, err := b.api.Send(msg); err != nil {
 		log.Printf("[bot] send message failed: %v", err)
@@ -122,61 +343,342 @@ func (b *Bot) reply(chatID int64, text string, replyTo int) {
 
 // Worker — обработчик задач очереди: скачивает и отправляет файл
 func (b *Bot) Worker(ctx context.Context, job queue.Job) {
-	path, size, ext, err := b.DL.Download(ctx, job.URL, job.Variant)
+	l := b.jobLoc(job)
+	res, err := b.DL.Download(ctx, DownloadRequest{Job: job, Thumbnail: true})
 	if err != nil {
-		b.reply(job.ChatID, fmt.Sprintf("Не удалось скачать: %v", err), 0)
+		// остановка бота: задача останется в журнале и продолжится после перезапуска
+		if ctx.Err() != nil {
+			return
+		}
+		if until, ok := downloader.RetryAt(err); ok {
+			b.delay(job, until)
+			return
+		}
+		log.Printf("[bot] download failed: chat=%d err=%v", job.ChatID, err)
+		text := downloadErrorText(l, downloader.KindOf(err))
+		var se *downloader.StepError
+		if errors.As(err, &se) {
+			text += "\n" + l.T("error.step", "step", se.Step)
+			b.reportStepFailure(job, se.Step, se.Err, se.Stderr)
+		}
+		// сырой stderr — только администраторам и не в инлайн-сообщение, которое видят все
+		if stderr := downloader.StderrOf(err); stderr != "" && b.cfg.IsAdmin(job.ChatID) && job.InlineMessageID == "" {
+			text += "\n\n[admin] " + truncateText(stderr, 1500)
+		}
+		b.notify(job, text)
 		return
 	}
-	if files.TooLarge(size, b.cfg.MaxFileMB) {
-		b.reply(job.ChatID, "Файл слишком большой для отправки ботом. Попробуйте качество 360p или Аудио MP3.", 0)
+	defer res.Release()
+	if files.TooLarge(res.Size, b.cfg.MaxFileMB) {
+		b.notify(job, l.T("file.too_large"))
 		return
 	}
 
+	// инлайн-режим: файл загружается в служебный чат (или личку) и подставляется по file_id
+	to := job.ChatID
+	if job.InlineMessageID != "" && b.cfg.InlineCacheChat != 0 {
+		to = b.cfg.InlineCacheChat
+	}
+
 	// выбор способа отправки
-	switch job.Variant {
-	case queue.VarAudioMP3:
-		a := tgbotapi.NewAudio(job.ChatID, tgbotapi.FilePath(path))
-		a.Caption = "Готово"
-		if _, err := b.api.Send(a); err != nil {
-			log.Printf("[bot] send audio failed: %v", err)
-			b.reply(job.ChatID, "Не удалось отправить файл.", 0)
+	caption, mode := "", ""
+	if !job.NoCaption {
+		caption, mode = b.caption(l, job.ChatID, job.URL, sponsorNote(l, res), res)
+	}
+	send := sendMethod(b.presets, job, res.Ext)
+	if send == config.SendVideo && res.StepFailed("compat") {
+		// не перекодировали — исходный VP9/AV1 видео не всем проиграется, документом дойдёт
+		send = config.SendDocument
+	}
+	var msg tgbotapi.Message
+	switch send {
+	case config.SendAudio:
+		msg, err = b.sendAudio(to, job.ThreadID, job.ReplyTo, res, caption, mode)
+	case config.SendVideo:
+		msg, err = b.sendVideo(to, job.ThreadID, job.ReplyTo, res, caption, mode)
+		if badRequest(err) {
+			// видео Telegram может не принять (кодек, размеры) — документом файл дойдёт;
+			// прочие ошибки (403, сеть, остановка) документ не исправит
+			log.Printf("[bot] send video failed, falling back to document: %v", err)
+			send = config.SendDocument
+			msg, err = b.sendDocument(to, job.ThreadID, job.ReplyTo, res, caption, mode)
 		}
 	default:
-		if ext == "mp4" {
-			v := tgbotapi.NewVideo(job.ChatID, tgbotapi.FilePath(path))
-			v.Caption = "Готово"
-			if _, err := b.api.Send(v); err != nil {
-				log.Printf("[bot] send video failed: %v", err)
-				b.reply(job.ChatID, "Не удалось отправить видео.", 0)
-			}
-		} else {
-			d := tgbotapi.NewDocument(job.ChatID, tgbotapi.FilePath(path))
-			d.Caption = "Готово"
-			if _, err := b.api.Send(d); err != nil {
-				log.Printf("[bot] send document failed: %v", err)
-				b.reply(job.ChatID, "Не удалось отправить файл.", 0)
-			}
+		msg, err = b.sendDocument(to, job.ThreadID, job.ReplyTo, res, caption, mode)
+	}
+	if err != nil {
+		log.Printf("[bot] send %s failed: %v", send, err)
+		text := l.T("send.failed")
+		switch {
+		case job.InlineMessageID != "" && to == job.ChatID && forbidden(err):
+			// без INLINE_CACHE_CHAT файл идёт в личку, а туда нельзя, пока пользователь не запустил бота
+			text = l.T("inline.start_bot")
+		case job.InlineMessageID != "":
+			text = l.T("send.failed_inline")
+		}
+		b.notify(job, text)
+		return
+	}
+
+	// file_id — для повторной отправки без загрузки (инлайн-режим)
+	file := cacheEntry(fileIDOf(msg), send, res)
+	if vid := videoID(job.URL); file.FileID != "" && vid != "" && job.LiveMinutes == 0 {
+		if err := b.files.Put(fileKey(vid, job), file); err != nil {
+			log.Printf("[bot] save file cache failed: %v", err)
+		}
+	}
+	b.reportSteps(job, l, res)
+	if job.InlineMessageID == "" {
+		return
+	}
+	if file.FileID == "" {
+		b.notify(job, l.T("send.failed"))
+		return
+	}
+	if err := b.deliverInline(job, file, caption, mode); err != nil {
+		log.Printf("[bot] edit inline media failed: %v", err)
+		b.notify(job, l.T("send.failed"))
+	}
+}
+
+// sendMethod — способ отправки из пресета; видео не в mp4 Telegram не проиграет — уходит документом
+func sendMethod(presets *config.Presets, job queue.Job, ext string) string {
+	send := config.SendVideo
+	if p, ok := presets.Get(string(job.Variant)); ok && job.LiveMinutes == 0 {
+		send = p.Send
+	}
+	if send == config.SendVideo && ext != "mp4" {
+		return config.SendDocument
+	}
+	return send
+}
+
+// statusText — состояние загрузчика и очереди для /status
+func (b *Bot) statusText(l i18n.Localizer) string {
+	var sb strings.Builder
+	if sr, ok := b.DL.(StatusReporter); ok {
+		sb.WriteString(sr.StatusText(l))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(l.N("status.queue", b.q.Len(), "workers", b.cfg.Concurrency))
+	if until := b.q.PausedUntil(); time.Now().Before(until) {
+		sb.WriteString("\n" + l.T("status.paused", "time", until.Format("15:04")))
+	}
+	return sb.String()
+}
+
+// maxDelays — сколько раз задачу можно отложить из-за rate limit YouTube
+const maxDelays = 3
+
+// delay — отложить задачу до окончания паузы вместо ошибки
+func (b *Bot) delay(job queue.Job, until time.Time) {
+	l := b.jobLoc(job)
+	job.Attempts++
+	if job.Attempts > maxDelays {
+		b.notify(job, downloadErrorText(l, downloader.KindRateLimited))
+		return
+	}
+	b.q.Pause(until)
+	if !b.q.TryEnqueue(job) {
+		log.Printf("[bot] queue full, dropping delayed job: chat=%d", job.ChatID)
+		b.notify(job, downloadErrorText(l, downloader.KindRateLimited))
+		return
+	}
+	log.Printf("[bot] job delayed until %s: chat=%d attempt=%d", until.Format(time.RFC3339), job.ChatID, job.Attempts)
+	b.notify(job, l.T("delayed", "time", until.Format("15:04")))
+}
+
+// Resume — вернуть в очередь задачи, прерванные перезапуском; загрузка продолжится из .part
+func (b *Bot) Resume(jobs []queue.Job) {
+	for _, j := range jobs {
+		b.q.Enqueue(j)
+		l := b.jobLoc(j)
+		b.notify(j, l.T("resumed", "label", jobLabel(l, b.presets, j)))
+	}
+}
+
+// GiveUp — сообщить о задачах, которые так и не завершились после перезапусков
+func (b *Bot) GiveUp(jobs []queue.Job) {
+	for _, j := range jobs {
+		l := b.jobLoc(j)
+		b.notify(j, l.T("resume.gave_up", "label", jobLabel(l, b.presets, j)))
+	}
+}
+
+// reportSteps — необязательные шаги постобработки, которые не удались: пользователю —
+// что файл отправлен без них, администраторам — подробности
+func (b *Bot) reportSteps(job queue.Job, l i18n.Localizer, res *DownloadResult) {
+	var failed []string
+	for _, ev := range res.Steps {
+		if ev.State != downloader.StepFail {
+			continue
 		}
+		b.reportStepFailure(job, ev.Step, ev.Err, "")
+		if ev.Step != "compat" {
+			failed = append(failed, ev.Step)
+		}
+	}
+	// инлайн-сообщение уже показывает файл — не затираем его
+	if job.InlineMessageID != "" {
+		return
+	}
+	if res.StepFailed("compat") {
+		b.replyIn(job.ChatID, job.ThreadID, l.T("compat.fallback"), job.ReplyTo)
+	}
+	if len(failed) > 0 {
+		b.replyIn(job.ChatID, job.ThreadID, l.T("postprocess.partial", "steps", strings.Join(failed, ", ")), job.ReplyTo)
 	}
 }
 
-var ytRe = regexp.MustCompile(`(?i)\bhttps?://(?:www\.)?(?:youtube\.com/watch\?v=[\w-]{6,}|youtu\.be/[\w-]{6,})\S*`)
+// reportStepFailure — сбой шага постобработки администраторам, каждому на его языке
+func (b *Bot) reportStepFailure(job queue.Job, step string, err error, stderr string) {
+	for _, id := range b.cfg.AdminIDs {
+		l := b.loc(&tgbotapi.User{ID: id})
+		text := l.T("admin.step_failed", "job", job.ID, "url", job.URL, "step", step, "error", fmt.Sprint(err))
+		if stderr != "" {
+			text += "\n" + truncateText(stderr, 1500)
+		}
+		b.reply(id, text, 0)
+	}
+}
+
+// NotifyAdmins — служебное сообщение всем администраторам из ADMIN_IDS, каждому на его языке
+func (b *Bot) NotifyAdmins(text func(l i18n.Localizer) string) {
+	for _, id := range b.cfg.AdminIDs {
+		b.reply(id, text(b.loc(&tgbotapi.User{ID: id})), 0)
+	}
+}
+
+// downloadErrorText — понятное пользователю сообщение по классу ошибки загрузки
+func downloadErrorText(l i18n.Localizer, k downloader.ErrorKind) string {
+	switch k {
+	case downloader.KindPrivate:
+		return l.T("error.private")
+	case downloader.KindRemoved:
+		return l.T("error.removed")
+	case downloader.KindGeoBlocked:
+		return l.T("error.geo")
+	case downloader.KindAgeRestricted:
+		return l.T("error.age")
+	case downloader.KindMembersOnly:
+		return l.T("error.members")
+	case downloader.KindLiveNotStarted:
+		return l.T("error.not_started")
+	case downloader.KindLive:
+		return l.T("error.live")
+	case downloader.KindRateLimited:
+		return l.T("error.rate_limit")
+	case downloader.KindBotCheck:
+		return l.T("error.bot_check")
+	case downloader.KindNetwork:
+		return l.T("error.network")
+	case downloader.KindDiskFull:
+		return l.T("error.disk_full")
+	case downloader.KindTimeout:
+		return l.T("error.timeout")
+	case downloader.KindPostProcess:
+		return l.T("error.postprocess")
+	default:
+		return l.T("error.unknown")
+	}
+}
+
+// badRequest — Telegram отклонил сам запрос (400): например, не принял файл как видео
+func badRequest(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == 400
+}
+
+// forbidden — Telegram не даёт писать в чат (бота не запускали или заблокировали)
+func forbidden(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == 403
+}
+
+// truncateText — обрезка по рунам, чтобы не резать UTF-8 посередине
+func truncateText(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
+var ytRe = regexp.MustCompile(`(?i)\bhttps?://(?:www\.)?(?:youtube\.com/watch\?v=[\w-]{6,}|youtube\.com/live/[\w-]{6,}|youtu\.be/[\w-]{6,})\S*`)
 
 func extractYouTubeURL(s string) string {
 	m := ytRe.FindString(s)
 	return strings.TrimSpace(m)
 }
 
-func buildKeyboard(token string) tgbotapi.InlineKeyboardMarkup {
-	b1 := tgbotapi.NewInlineKeyboardButtonData("Видео 360p", fmt.Sprintf("t=%s;v=360", token))
-	b2 := tgbotapi.NewInlineKeyboardButtonData("HD 720p", fmt.Sprintf("t=%s;v=720", token))
-	b3 := tgbotapi.NewInlineKeyboardButtonData("Full HD 1080p", fmt.Sprintf("t=%s;v=1080", token))
-	b4 := tgbotapi.NewInlineKeyboardButtonData("2K 1440p", fmt.Sprintf("t=%s;v=1440", token))
-	b5 := tgbotapi.NewInlineKeyboardButtonData("Аудио MP3", fmt.Sprintf("t=%s;v=mp3", token))
-	row1 := tgbotapi.NewInlineKeyboardRow(b1, b2)
-	row2 := tgbotapi.NewInlineKeyboardRow(b3, b4)
-	row3 := tgbotapi.NewInlineKeyboardRow(b5)
-	return tgbotapi.NewInlineKeyboardMarkup(row1, row2, row3)
+// buildKeyboard — кнопки пресетов по строкам реестра и переключатель SponsorBlock; def — пресет
+// пользователя по умолчанию, отмечается звёздой
+func buildKeyboard(l i18n.Localizer, token string, sb queue.SponsorBlock, presets *config.Presets, def string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range presets.Rows() {
+		var btns []tgbotapi.InlineKeyboardButton
+		for _, p := range row {
+			label := p.LabelIn(l.Lang)
+			if p.ID == def {
+				label = "⭐ " + label
+			}
+			btns = append(btns, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("t=%s;v=%s", token, p.Code)))
+		}
+		rows = append(rows, btns)
+	}
+	sbBtn := tgbotapi.NewInlineKeyboardButtonData(l.T("sponsor.button", "mode", sponsorLabel(l, sb.Mode)), fmt.Sprintf("t=%s;sb=next", token))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(sbBtn))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// варианты длительности записи трансляции «с текущего момента», минуты
+var liveDurations = []int{10, 30, 60}
+
+func buildLiveKeyboard(l i18n.Localizer, token string, maxMinutes int) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, m := range liveDurations {
+		if m > maxMinutes {
+			break
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(l.T("live.button", "n", m), fmt.Sprintf("t=%s;v=live%d", token, m)))
+	}
+	if len(row) == 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(l.T("live.button", "n", maxMinutes), fmt.Sprintf("t=%s;v=live%d", token, maxMinutes)))
+	}
+	start := tgbotapi.NewInlineKeyboardButtonData(l.T("live.button_start", "n", maxMinutes), fmt.Sprintf("t=%s;v=livestart", token))
+	return tgbotapi.NewInlineKeyboardMarkup(row, tgbotapi.NewInlineKeyboardRow(start))
+}
+
+// parseLiveVariant — live<N> или livestart; длительность ограничена maxMinutes
+func parseLiveVariant(v string, maxMinutes int) (minutes int, fromStart, ok bool) {
+	if v == "livestart" {
+		return maxMinutes, true, true
+	}
+	if !strings.HasPrefix(v, "live") {
+		return 0, false, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(v, "live"))
+	if err != nil || n <= 0 {
+		return 0, false, false
+	}
+	if n > maxMinutes {
+		n = maxMinutes
+	}
+	return n, false, true
+}
+
+func humanLive(l i18n.Localizer, job queue.Job) string {
+	if job.LiveFromStart {
+		return l.T("live.from_start", "n", job.LiveMinutes)
+	}
+	return l.N("live.next", job.LiveMinutes)
+}
+
+func upcomingText(l i18n.Localizer, info *downloader.Info) string {
+	if t, ok := info.StartsAt(); ok {
+		return l.T("upcoming.at", "time", t.Format("02.01 15:04"))
+	}
+	return l.T("upcoming")
 }
 
 func parseCallbackData(data string) (token, variant string) {
@@ -193,36 +695,28 @@ func parseCallbackData(data string) (token, variant string) {
 	return
 }
 
-func toVariant(v string) queue.Variant {
-	switch v {
-	case "360":
-		return queue.VarVideo360
-	case "720":
-		return queue.VarVideo720
-	case "1080":
-		return queue.VarVideo1080
-	case "1440":
-		return queue.VarVideo1440
-	case "mp3":
-		return queue.VarAudioMP3
-	default:
-		return queue.VarVideo360
-	}
-}
-
-func humanVariant(v queue.Variant) string {
-	switch v {
-	case queue.VarVideo360:
-		return "Видео 360p"
-	case queue.VarVideo720:
-		return "HD 720p"
-	case queue.VarVideo1080:
-		return "Full HD 1080p"
-	case queue.VarVideo1440:
-		return "2K 1440p"
-	case queue.VarAudioMP3:
-		return "Аудио MP3"
-	default:
-		return string(v)
+// callbackField — значение поля key=… из callback data
+func callbackField(data, key string) string {
+	for _, p := range strings.Split(data, ";") {
+		if strings.HasPrefix(p, key+"=") {
+			return strings.TrimPrefix(p, key+"=")
+		}
+	}
+	return ""
+}
+
+// jobLabel — что качает задача: запись трансляции или название пресета
+func jobLabel(l i18n.Localizer, presets *config.Presets, job queue.Job) string {
+	if job.LiveMinutes > 0 {
+		return humanLive(l, job)
+	}
+	return presetLabel(l, presets, job.Variant)
+}
+
+// presetLabel — название варианта задачи для сообщений; неизвестный — как есть
+func presetLabel(l i18n.Localizer, presets *config.Presets, v queue.Variant) string {
+	if p, ok := presets.Get(string(v)); ok {
+		return p.LabelIn(l.Lang)
 	}
+	return string(v)
 }
@@ -2,6 +2,8 @@ package telegram
 
 import (
     "context"
+    "encoding/json"
+    "errors"
     "fmt"
     "sync"
     "testing"
@@ -9,8 +11,13 @@ import (
 
     tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
+    "net/http"
+    "net/http/httptest"
     "os"
+    "strings"
     "youtube-bot-simple/internal/config"
+    "youtube-bot-simple/internal/downloader"
+    "youtube-bot-simple/internal/i18n"
     "youtube-bot-simple/internal/queue"
     "youtube-bot-simple/internal/state"
 )
@@ -18,10 +25,13 @@ import (
 // fakeAPI implements Sender and records sent messages for inspection.
 type fakeAPI struct {
     calls chan tgbotapi.Chattable
+    reqs  chan tgbotapi.Chattable
     mu    sync.Mutex
 }
 
-func newFakeAPI() *fakeAPI { return &fakeAPI{calls: make(chan tgbotapi.Chattable, 32)} }
+func newFakeAPI() *fakeAPI {
+    return &fakeAPI{calls: make(chan tgbotapi.Chattable, 32), reqs: make(chan tgbotapi.Chattable, 32)}
+}
 
 func (f *fakeAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
     f.calls <- c
@@ -29,22 +39,20 @@ func (f *fakeAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 }
 
 func (f *fakeAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
-    // Record requests as well if useful in the future
+    select {
+    case f.reqs <- c:
+    default:
+    }
     return &tgbotapi.APIResponse{Ok: true}, nil
 }
 
-func (f *fakeAPI) GetUpdatesChan(u tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
-    var ch tgbotapi.UpdatesChannel
-    return ch
-}
-
 // fakeRunner implements Downloader and creates small temp files.
 type fakeRunner struct{ dir string }
 
-func (fr *fakeRunner) Download(ctx context.Context, url string, v queue.Variant) (string, int64, string, error) {
+func (fr *fakeRunner) Download(ctx context.Context, req DownloadRequest) (*DownloadResult, error) {
     var name, ext string
-    switch v {
-    case queue.VarAudioMP3:
+    switch req.Variant {
+    case queue.Variant("audioMp3"):
         name, ext = "test_audio.mp3", "mp3"
     default:
         name, ext = "test_video.mp4", "mp4"
@@ -52,9 +60,9 @@ func (fr *fakeRunner) Download(ctx context.Context, url string, v queue.Variant)
     path := fr.dir + "/" + name
     data := []byte("dummy content")
     if err := os.WriteFile(path, data, 0o644); err != nil {
-        return "", 0, "", err
+        return nil, err
     }
-    return path, int64(len(data)), ext, nil
+    return &DownloadResult{Path: path, Size: int64(len(data)), Ext: ext, Preset: string(req.Variant)}, nil
 }
 
 // writeFile is implemented below with a real os.WriteFile call.
@@ -171,4 +179,762 @@ func TestTelegramFlow_MessageToKeyboard_And_CallbackToWorker(t *testing.T) {
     }
 }
 
+// fileAPI — fakeAPI, который возвращает file_id отправленного видео/аудио
+type fileAPI struct{ *fakeAPI }
+
+func (f fileAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+    msg, err := f.fakeAPI.Send(c)
+    switch c.(type) {
+    case tgbotapi.VideoConfig:
+        msg.Video = &tgbotapi.Video{FileID: "video-file-id"}
+    case tgbotapi.AudioConfig:
+        msg.Audio = &tgbotapi.Audio{FileID: "audio-file-id"}
+    }
+    return msg, err
+}
+
+func waitForRequest[T any](ch <-chan tgbotapi.Chattable, timeout time.Duration) (T, bool) {
+    var zero T
+    deadline := time.After(timeout)
+    for {
+        select {
+        case <-deadline:
+            return zero, false
+        case c := <-ch:
+            if v, ok := c.(T); ok {
+                return v, true
+            }
+        }
+    }
+}
+
+func TestInlineFlow_PendingResultThenCachedFileID(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    tmp := t.TempDir()
+    cfg := &config.Config{DownloadDir: tmp, MaxFileMB: 50, CmdTimeoutSec: 5}
+    q := queue.NewQueue(10, 1)
+    api := fileAPI{newFakeAPI()}
+    b := NewBot(api, cfg, state.NewStore(), q, &fakeRunner{dir: tmp})
+    q.Start(ctx, b.Worker)
+
+    from := &tgbotapi.User{ID: 42}
+    query := &tgbotapi.InlineQuery{ID: "iq1", From: from, Query: "https://youtu.be/dQw4w9WgXcQ"}
+
+    // 1) кэш пуст — заглушки с кнопкой для каждого пресета
+    b.handleInlineQuery(query)
+    ans, ok := waitForRequest[tgbotapi.InlineConfig](api.reqs, 2*time.Second)
+    if !ok || len(ans.Results) != len(b.presets.All()) {
+        t.Fatalf("inline answer = %+v", ans)
+    }
+    photo, ok := ans.Results[0].(tgbotapi.InlineQueryResultPhoto)
+    if !ok || photo.ReplyMarkup == nil {
+        t.Fatalf("expected pending photo result with keyboard, got %T", ans.Results[0])
+    }
+
+    // 2) выбор заглушки — загрузка и подмена медиа в инлайн-сообщении
+    b.handleChosenInline(ctx, &tgbotapi.ChosenInlineResult{ResultID: photo.ID, From: from, InlineMessageID: "inl-1"})
+    edit, ok := waitForRequest[tgbotapi.EditMessageMediaConfig](api.reqs, 3*time.Second)
+    if !ok || edit.InlineMessageID != "inl-1" {
+        t.Fatalf("expected editMessageMedia for inline message, got %+v", edit)
+    }
+    if m, ok := edit.Media.(tgbotapi.InputMediaVideo); !ok || m.Media != tgbotapi.FileID("video-file-id") {
+        t.Fatalf("edited media = %+v", edit.Media)
+    }
+
+    // 3) повторный запрос — готовый файл по file_id
+    b.handleInlineQuery(query)
+    ans, ok = waitForRequest[tgbotapi.InlineConfig](api.reqs, 2*time.Second)
+    if !ok {
+        t.Fatal("no inline answer")
+    }
+    cached, ok := ans.Results[0].(tgbotapi.InlineQueryResultCachedVideo)
+    if !ok || cached.VideoID != "video-file-id" {
+        t.Fatalf("expected cached video result, got %+v", ans.Results[0])
+    }
+    if _, ok := ans.Results[1].(tgbotapi.InlineQueryResultPhoto); !ok {
+        t.Fatalf("other presets should stay pending, got %T", ans.Results[1])
+    }
+
+    // 4) другой пользователь получает тот же file_id, но подпись по своему шаблону
+    other := &tgbotapi.User{ID: 43}
+    if err := b.prefs.Update(other.ID, func(u *state.UserPrefs) { u.Caption = "via {url}" }); err != nil {
+        t.Fatal(err)
+    }
+    b.handleInlineQuery(&tgbotapi.InlineQuery{ID: "iq2", From: other, Query: query.Query})
+    ans, ok = waitForRequest[tgbotapi.InlineConfig](api.reqs, 2*time.Second)
+    if !ok {
+        t.Fatal("no inline answer")
+    }
+    mine, ok := ans.Results[0].(tgbotapi.InlineQueryResultCachedVideo)
+    if !ok || mine.VideoID != "video-file-id" || mine.Caption != "via https://youtu.be/dQw4w9WgXcQ" || mine.Caption == cached.Caption {
+        t.Fatalf("cached result for another user = %+v (first user caption %q)", ans.Results[0], cached.Caption)
+    }
+}
+
+func TestGroupMode_ForumTopicStoredOnJob(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    q := queue.NewQueue(10, 1)
+    b := NewBot(newFakeAPI(), &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50}, state.NewStore(), q, &fakeRunner{})
+    jobs := make(chan queue.Job, 1)
+    q.Start(ctx, func(_ context.Context, j queue.Job) { jobs <- j })
+    if err := b.prefs.Update(-100, func(u *state.UserPrefs) { u.Group = &state.GroupPrefs{Default: "video720"} }); err != nil {
+        t.Fatal(err)
+    }
+
+    // ссылка в теме форума: номер темы есть только в сыром обновлении
+    raw := []byte(`{"update_id":5,"message":{"message_id":10,"message_thread_id":7,"is_topic_message":true,` +
+        `"chat":{"id":-100,"type":"supergroup"},"from":{"id":3},"text":"https://youtu.be/dQw4w9WgXcQ"}}`)
+    b.threads.record(raw)
+    var u tgbotapi.Update
+    if err := json.Unmarshal(raw, &u); err != nil {
+        t.Fatal(err)
+    }
+    b.dispatch(ctx, u)
+
+    select {
+    case j := <-jobs:
+        if j.ThreadID != 7 || j.ReplyTo != 10 {
+            t.Fatalf("job thread=%d replyTo=%d; want 7/10", j.ThreadID, j.ReplyTo)
+        }
+    case <-time.After(2 * time.Second):
+        t.Fatal("link in topic was not queued")
+    }
+}
+
+// forbiddenAPI — личка пользователя закрыта: он ни разу не запускал бота
+type forbiddenAPI struct{ *fakeAPI }
+
+func (f forbiddenAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+    return tgbotapi.Message{}, &tgbotapi.Error{Code: 403, Message: "Forbidden: bot can't initiate conversation with a user"}
+}
+
+func TestInlineFlow_NoCacheChatExplainsStart(t *testing.T) {
+    t.Parallel()
+    tmp := t.TempDir()
+    api := forbiddenAPI{newFakeAPI()}
+    b := NewBot(api, &config.Config{DownloadDir: tmp, MaxFileMB: 50}, state.NewStore(), queue.NewQueue(10, 1), &fakeRunner{dir: tmp})
+
+    // без INLINE_CACHE_CHAT файл загружается в личку — пользователь узнаёт, что нужно запустить бота
+    b.Worker(context.Background(), queue.Job{ChatID: 42, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720"), InlineMessageID: "inl-2"})
+    edit, ok := waitForRequest[tgbotapi.EditMessageCaptionConfig](api.reqs, time.Second)
+    if !ok || edit.InlineMessageID != "inl-2" || !strings.Contains(edit.Caption, "/start") {
+        t.Fatalf("inline notice = %+v", edit)
+    }
+}
+
+func TestGroupMode_OnlyLinksCommandsAndMentions(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, CmdTimeoutSec: 5}
+    q := queue.NewQueue(10, 1)
+    api := newFakeAPI()
+    b := NewBot(api, cfg, state.NewStore(), q, &fakeRunner{dir: cfg.DownloadDir})
+    b.UseUsername("ytbot")
+    jobs := make(chan queue.Job, 4)
+    q.Start(ctx, func(_ context.Context, j queue.Job) { jobs <- j })
+
+    group := &tgbotapi.Chat{ID: -100, Type: "supergroup"}
+    cmd := func(text string) []tgbotapi.MessageEntity {
+        if text[0] != '/' {
+            return nil
+        }
+        return []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(strings.Fields(text)[0])}}
+    }
+    send := func(id int, text string) {
+        b.handleMessage(ctx, &tgbotapi.Message{MessageID: id, Chat: group, From: &tgbotapi.User{ID: 7}, Text: text, Entities: cmd(text)})
+    }
+
+    // обычная переписка и команды другим ботам — тишина
+    send(1, "всем привет")
+    send(2, "/help@otherbot")
+    select {
+    case c := <-api.calls:
+        t.Fatalf("bot should stay silent in group, sent %#v", c)
+    case <-time.After(100 * time.Millisecond):
+    }
+
+    // упоминание без ссылки — подсказка ответом на сообщение
+    send(3, "@ytbot что умеешь?")
+    if mc, ok := waitForMessageConfig(api.calls, time.Second); !ok || mc.ReplyToMessageID != 3 {
+        t.Fatalf("expected reply to mention, got %#v", mc)
+    }
+
+    // ссылка — клавиатура ответом (та же тема форума) только с разрешёнными вариантами
+    _ = b.prefs.Update(-100, func(u *state.UserPrefs) {
+        u.Group = &state.GroupPrefs{Variants: []string{"video720", "audioMp3"}}
+    })
+    send(4, "https://youtu.be/dQw4w9WgXcQ")
+    mc, ok := waitForMessageConfig(api.calls, time.Second)
+    if !ok || mc.ReplyToMessageID != 4 {
+        t.Fatalf("expected keyboard reply, got %#v", mc)
+    }
+    mk := mc.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+    if n := len(mk.InlineKeyboard); n != 3 { // 720, MP3, SponsorBlock
+        t.Fatalf("keyboard rows = %d; want 3", n)
+    }
+
+    // качество по умолчанию — сразу в очередь, файл уйдёт ответом на ссылку
+    _ = b.prefs.Update(-100, func(u *state.UserPrefs) { u.Group.Default = "audioMp3" })
+    send(5, "https://youtu.be/dQw4w9WgXcQ")
+    select {
+    case j := <-jobs:
+        if j.Variant != queue.Variant("audioMp3") || j.ReplyTo != 5 || j.ChatID != -100 {
+            t.Fatalf("job = %+v", j)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("expected job from group default")
+    }
+}
+
+// localBotAPI — стенд локального telegram-bot-api: запоминает параметры и файлы sendVideo
+type localBotAPI struct {
+    mu     sync.Mutex
+    video  []string
+    upload bool
+}
+
+func (l *localBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    switch {
+    case strings.HasSuffix(r.URL.Path, "/getMe"):
+        fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"ytbot"}}`)
+    case strings.HasSuffix(r.URL.Path, "/sendVideo"):
+        if err := r.ParseMultipartForm(1 << 20); err != nil {
+            _ = r.ParseForm()
+        }
+        l.mu.Lock()
+        l.video = append(l.video, r.FormValue("video"))
+        l.upload = l.upload || (r.MultipartForm != nil && len(r.MultipartForm.File) > 0)
+        l.mu.Unlock()
+        fmt.Fprint(w, `{"ok":true,"result":{"message_id":5,"date":0,"chat":{"id":1234,"type":"private"},"video":{"file_id":"big-file","file_unique_id":"u","width":1920,"height":1080,"duration":1}}}`)
+    default:
+        fmt.Fprint(w, `{"ok":true,"result":true}`)
+    }
+}
+
+func TestLocalBotAPI_SendsFileByPath(t *testing.T) {
+    t.Parallel()
+    stand := &localBotAPI{}
+    srv := httptest.NewServer(stand)
+    defer srv.Close()
+
+    api, err := tgbotapi.NewBotAPIWithAPIEndpoint("123:abc", srv.URL+"/bot%s/%s")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tmp := t.TempDir()
+    cfg := &config.Config{DownloadDir: tmp, MaxFileMB: config.LocalUploadMB, CmdTimeoutSec: 5, LocalBotAPI: true}
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), &fakeRunner{dir: tmp})
+
+    // через Worker (VideoConfig) и прямым вызовом с размерами (UploadFiles)
+    b.Worker(context.Background(), queue.Job{ChatID: 1234, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+    path := tmp + "/big.mp4"
+    if _, err := b.sendVideo(1234, 0, 0, &DownloadResult{Path: path, Width: 1920, Height: 1080}, "", ""); err != nil {
+        t.Fatal(err)
+    }
+
+    stand.mu.Lock()
+    defer stand.mu.Unlock()
+    want := []string{"file://" + tmp + "/test_video.mp4", "file://" + path}
+    if fmt.Sprint(stand.video) != fmt.Sprint(want) || stand.upload {
+        t.Fatalf("sendVideo video=%v upload=%v; want %v without multipart files", stand.video, stand.upload, want)
+    }
+    if f, ok := b.files.Get(fileKey("dQw4w9WgXcQ", queue.Job{Variant: queue.Variant("video720")})); !ok || f.FileID != "big-file" {
+        t.Fatalf("file_id not cached: %+v", f)
+    }
+}
+
+// videoFailAPI — fakeAPI, который отвечает на sendVideo ошибкой err
+type videoFailAPI struct {
+    *fakeAPI
+    err error
+}
+
+func (f videoFailAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+    if _, ok := c.(tgbotapi.VideoConfig); ok {
+        return tgbotapi.Message{}, f.err
+    }
+    return f.fakeAPI.Send(c)
+}
+
+func TestWorker_VideoFallsBackToDocument(t *testing.T) {
+    t.Parallel()
+    tmp := t.TempDir()
+    cfg := &config.Config{DownloadDir: tmp, MaxFileMB: 50, CmdTimeoutSec: 5}
+    api := videoFailAPI{newFakeAPI(), &tgbotapi.Error{Code: 400, Message: "Bad Request: wrong file"}}
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), &fakeRunner{dir: tmp})
+
+    b.Worker(context.Background(), queue.Job{ChatID: 1234, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+    select {
+    case c := <-api.calls:
+        if _, ok := c.(tgbotapi.DocumentConfig); !ok {
+            t.Fatalf("expected DocumentConfig fallback, got %T", c)
+        }
+    default:
+        t.Fatal("nothing sent after video failure")
+    }
+
+    // бот заблокирован, сеть, остановка — документом файл не дойдёт тоже, повторно не загружаем
+    for _, err := range []error{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, errors.New("connection reset"), context.Canceled} {
+        api := videoFailAPI{newFakeAPI(), err}
+        b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), &fakeRunner{dir: tmp})
+        b.Worker(context.Background(), queue.Job{ChatID: 1234, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+        select {
+        case c := <-api.calls:
+            if mc, ok := c.(tgbotapi.MessageConfig); !ok || mc.Text != "Не удалось отправить файл." {
+                t.Fatalf("%v: expected send.failed message, got %T %+v", err, c, c)
+            }
+        default:
+            t.Fatalf("%v: user not told about the failure", err)
+        }
+    }
+}
+
+// rateLimitedRunner — загрузчик, у которого открыт предохранитель до until
+type rateLimitedRunner struct{ until time.Time }
+
+func (r rateLimitedRunner) Download(ctx context.Context, req DownloadRequest) (*DownloadResult, error) {
+    return nil, &downloader.Error{Kind: downloader.KindRateLimited, Err: downloader.ErrCircuitOpen, RetryAt: r.until}
+}
+
+func TestWorker_RateLimitDelaysJob(t *testing.T) {
+    t.Parallel()
+    until := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+    q := queue.NewQueue(10, 1)
+    api := newFakeAPI()
+    b := NewBot(api, &config.Config{MaxFileMB: 50}, state.NewStore(), q, rateLimitedRunner{until: until})
+
+    // задача не падает, а возвращается в очередь, которая ждёт окончания паузы
+    b.Worker(context.Background(), queue.Job{ChatID: 5, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+    mc, ok := waitForMessageConfig(api.calls, time.Second)
+    if !ok || mc.Text != "YouTube временно ограничивает загрузки. Задача отложена до "+until.Format("15:04")+" — файл придёт автоматически." {
+        t.Fatalf("delayed message = %q", mc.Text)
+    }
+    if q.Len() != 1 || !q.PausedUntil().Equal(until) {
+        t.Fatalf("queue len=%d paused=%v; want re-enqueued and paused until %v", q.Len(), q.PausedUntil(), until)
+    }
+}
+
+func TestWorker_RateLimitBeforeBreakerDelaysJob(t *testing.T) {
+    t.Parallel()
+    q := queue.NewQueue(10, 1)
+    api := newFakeAPI()
+    // первый 429: предохранитель ещё закрыт, время повтора загрузчик не знает
+    b := NewBot(api, &config.Config{MaxFileMB: 50}, state.NewStore(), q, rateLimitedRunner{})
+
+    b.Worker(context.Background(), queue.Job{ChatID: 5, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+    mc, ok := waitForMessageConfig(api.calls, time.Second)
+    if !ok || !strings.HasPrefix(mc.Text, "YouTube временно ограничивает загрузки. Задача отложена до ") {
+        t.Fatalf("delayed message = %q", mc.Text)
+    }
+    if q.Len() != 1 || !time.Now().Before(q.PausedUntil()) {
+        t.Fatalf("queue len=%d paused=%v; want re-enqueued after a short pause", q.Len(), q.PausedUntil())
+    }
+}
+
+func TestWorker_RateLimitGivesUpAfterMaxDelays(t *testing.T) {
+    t.Parallel()
+    q := queue.NewQueue(10, 1)
+    api := newFakeAPI()
+    b := NewBot(api, &config.Config{MaxFileMB: 50}, state.NewStore(), q, rateLimitedRunner{until: time.Now().Add(time.Minute)})
+
+    // после maxDelays откладываний — обычная ошибка rate limit, задача больше не возвращается
+    b.Worker(context.Background(), queue.Job{ChatID: 5, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720"), Attempts: maxDelays})
+    mc, ok := waitForMessageConfig(api.calls, time.Second)
+    if !ok || mc.Text != "YouTube временно ограничил запросы. Попробуйте через несколько минут." {
+        t.Fatalf("give-up message = %q", mc.Text)
+    }
+    if q.Len() != 0 || !q.PausedUntil().IsZero() {
+        t.Fatalf("queue len=%d paused=%v; want job dropped without pause", q.Len(), q.PausedUntil())
+    }
+}
+
+// stepRunner — загрузчик, у которого не удаётся шаг постобработки
+type stepRunner struct {
+    dir    string
+    failed string // необязательный шаг, который не удался
+    err    error  // сбой обязательного шага
+}
+
+func (r stepRunner) Download(ctx context.Context, req DownloadRequest) (*DownloadResult, error) {
+    if r.err != nil {
+        return nil, r.err
+    }
+    path := r.dir + "/test_video.mp4"
+    if err := os.WriteFile(path, []byte("dummy"), 0o644); err != nil {
+        return nil, err
+    }
+    steps := []downloader.StepEvent{
+        {Step: "remux", Index: 1, Total: 2, State: downloader.StepDone},
+        {Step: r.failed, Index: 2, Total: 2, State: downloader.StepFail, Err: errors.New("exit status 1")},
+    }
+    return &DownloadResult{Path: path, Size: 5, Ext: "mp4", Preset: string(req.Variant), Steps: steps}, nil
+}
+
+func TestWorker_ReportsFailedSteps(t *testing.T) {
+    t.Parallel()
+    api := newFakeAPI()
+    cfg := &config.Config{MaxFileMB: 50, AdminIDs: []int64{99}}
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), stepRunner{dir: t.TempDir(), failed: "loudnorm"})
+
+    b.Worker(context.Background(), queue.Job{ID: "j1", ChatID: 5, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+    if _, ok := waitForVideoConfig(api.calls, time.Second); !ok {
+        t.Fatal("file was not sent")
+    }
+    texts := map[int64]string{}
+    for i := 0; i < 2; i++ {
+        mc, ok := waitForMessageConfig(api.calls, time.Second)
+        if !ok {
+            t.Fatalf("got %d step reports; want admin and user", i)
+        }
+        texts[mc.ChatID] = mc.Text
+    }
+    if texts[5] != "Не все шаги обработки удались (loudnorm) — файл отправлен без них." {
+        t.Fatalf("user note = %q", texts[5])
+    }
+    if !strings.Contains(texts[99], "j1") || !strings.Contains(texts[99], "loudnorm") || !strings.Contains(texts[99], "exit status 1") {
+        t.Fatalf("admin note = %q", texts[99])
+    }
+}
+
+func TestWorker_CompatFailureSendsDocument(t *testing.T) {
+    t.Parallel()
+    api := newFakeAPI()
+    b := NewBot(api, &config.Config{MaxFileMB: 50}, state.NewStore(), queue.NewQueue(10, 1), stepRunner{dir: t.TempDir(), failed: "compat"})
+
+    // перекодирование не удалось — исходный файл уходит документом, пользователь узнаёт почему
+    b.Worker(context.Background(), queue.Job{ID: "j3", ChatID: 5, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video1080")})
+    if _, ok := (<-api.calls).(tgbotapi.DocumentConfig); !ok {
+        t.Fatal("original file was not sent as a document")
+    }
+    mc, ok := waitForMessageConfig(api.calls, time.Second)
+    if !ok || mc.Text != "Перекодировать видео для Telegram не удалось — отправил исходный файл документом." {
+        t.Fatalf("fallback note = %q", mc.Text)
+    }
+    select {
+    case c := <-api.calls:
+        t.Fatalf("unexpected extra message %#v", c)
+    default:
+    }
+}
+
+func TestWorker_ReportsStepError(t *testing.T) {
+    t.Parallel()
+    api := newFakeAPI()
+    cfg := &config.Config{MaxFileMB: 50, AdminIDs: []int64{99}}
+    se := &downloader.StepError{Step: "remux", Stderr: "moov atom not found", Err: errors.New("exit status 1")}
+    runner := stepRunner{err: &downloader.Error{Kind: downloader.KindPostProcess, Stderr: se.Stderr, Err: se}}
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), runner)
+
+    b.Worker(context.Background(), queue.Job{ID: "j2", ChatID: 5, URL: "https://youtu.be/dQw4w9WgXcQ", Variant: queue.Variant("video720")})
+    texts := map[int64]string{}
+    for i := 0; i < 2; i++ {
+        mc, ok := waitForMessageConfig(api.calls, time.Second)
+        if !ok {
+            t.Fatalf("got %d messages; want admin and user", i)
+        }
+        texts[mc.ChatID] = mc.Text
+    }
+    if !strings.HasSuffix(texts[5], "Шаг обработки remux не удался.") || strings.Contains(texts[5], "moov") {
+        t.Fatalf("user error = %q", texts[5])
+    }
+    if !strings.Contains(texts[99], "remux") || !strings.Contains(texts[99], "moov atom not found") {
+        t.Fatalf("admin note = %q", texts[99])
+    }
+}
+
+// probingRunner — fakeRunner, который отвечает на опрос заданным статусом
+type probingRunner struct {
+    fakeRunner
+    info downloader.Info
+}
+
+func (r *probingRunner) Probe(ctx context.Context, url string) (*downloader.Info, error) {
+    info := r.info
+    return &info, nil
+}
+
+func waitForEdit(ch <-chan tgbotapi.Chattable, timeout time.Duration) (tgbotapi.EditMessageTextConfig, bool) {
+    var zero tgbotapi.EditMessageTextConfig
+    deadline := time.After(timeout)
+    for {
+        select {
+        case <-deadline:
+            return zero, false
+        case c := <-ch:
+            if v, ok := c.(tgbotapi.EditMessageTextConfig); ok {
+                return v, true
+            }
+        }
+    }
+}
+
+func TestLink_KeyboardFirstThenLiveProbe(t *testing.T) {
+    t.Parallel()
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, CmdTimeoutSec: 5, LiveMaxMinutes: 30}
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    dl := &probingRunner{fakeRunner: fakeRunner{dir: cfg.DownloadDir}, info: downloader.Info{LiveStatus: downloader.LiveNow}}
+    api := newFakeAPI()
+    q := queue.NewQueue(10, 1)
+    jobs := make(chan queue.Job, 1)
+    q.Start(ctx, func(_ context.Context, j queue.Job) { jobs <- j })
+    b := NewBot(api, cfg, state.NewStore(), q, dl)
+    chat := &tgbotapi.Chat{ID: 44, Type: "private"}
+
+    // клавиатура приходит, не дожидаясь опроса; затем превращается в запись трансляции
+    b.handleMessage(context.Background(), &tgbotapi.Message{MessageID: 1, Chat: chat, Text: "https://youtu.be/dQw4w9WgXcQ"})
+    if mc, ok := waitForMessageConfig(api.calls, time.Second); !ok || mc.Text != "Выберите вариант загрузки:" {
+        t.Fatalf("keyboard = %q", mc.Text)
+    }
+    edit, ok := waitForEdit(api.reqs, time.Second)
+    if !ok || edit.Text != "Это прямая трансляция. Что записать?" || edit.ReplyMarkup == nil {
+        t.Fatalf("live edit = %+v", edit)
+    }
+    data := *edit.ReplyMarkup.InlineKeyboard[0][0].CallbackData
+    if !strings.HasSuffix(data, "v=live10") {
+        t.Fatalf("live button = %q", data)
+    }
+
+    // запись — отдельный вариант, а не чужой пресет, которого может не быть в PRESETS_FILE
+    b.handleCallback(ctx, &tgbotapi.CallbackQuery{ID: "c", From: &tgbotapi.User{ID: 44}, Message: &tgbotapi.Message{MessageID: 2, Chat: chat}, Data: data})
+    if j := <-jobs; j.Variant != queue.VarLive || j.LiveMinutes != 10 {
+        t.Fatalf("live job = %+v", j)
+    }
+}
+
+func TestLink_ProbeUpcomingAndEnded(t *testing.T) {
+    t.Parallel()
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, CmdTimeoutSec: 5, LiveMaxMinutes: 30}
+    dl := &probingRunner{fakeRunner: fakeRunner{dir: cfg.DownloadDir}}
+    api := newFakeAPI()
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), dl)
+    chat := &tgbotapi.Chat{ID: 45, Type: "private"}
+    send := func(info downloader.Info) tgbotapi.EditMessageTextConfig {
+        dl.info = info
+        b.handleMessage(context.Background(), &tgbotapi.Message{MessageID: 1, Chat: chat, Text: "https://youtu.be/dQw4w9WgXcQ"})
+        if _, ok := waitForMessageConfig(api.calls, time.Second); !ok {
+            t.Fatal("no keyboard")
+        }
+        edit, ok := waitForEdit(api.reqs, time.Second)
+        if !ok {
+            t.Fatal("keyboard not edited after probe")
+        }
+        return edit
+    }
+
+    // премьера: кнопки убираются
+    if edit := send(downloader.Info{LiveStatus: downloader.LiveUpcoming}); !strings.HasPrefix(edit.Text, "Трансляция или премьера ещё не началась") || edit.ReplyMarkup != nil {
+        t.Fatalf("upcoming edit = %+v", edit)
+    }
+    // прошедший эфир: обычные кнопки и пояснение с длительностью
+    edit := send(downloader.Info{LiveStatus: downloader.LiveWas, Duration: 5400})
+    if edit.Text != "Это запись прошедшей трансляции (90 минут). Что скачать?" || edit.ReplyMarkup == nil || len(edit.ReplyMarkup.InlineKeyboard) < 2 {
+        t.Fatalf("was_live edit = %+v", edit)
+    }
+}
+
+func TestLanguage_DetectedAndOverridden(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, CmdTimeoutSec: 5, LiveMaxMinutes: 30}
+    q := queue.NewQueue(10, 1)
+    api := newFakeAPI()
+    b := NewBot(api, cfg, state.NewStore(), q, &fakeRunner{dir: cfg.DownloadDir})
+    jobs := make(chan queue.Job, 4)
+    q.Start(ctx, func(_ context.Context, j queue.Job) { jobs <- j })
+
+    chat := &tgbotapi.Chat{ID: 42, Type: "private"}
+    from := &tgbotapi.User{ID: 42, LanguageCode: "en-GB"}
+    send := func(text string) tgbotapi.MessageConfig {
+        var ents []tgbotapi.MessageEntity
+        if text[0] == '/' {
+            ents = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(strings.Fields(text)[0])}}
+        }
+        b.handleMessage(ctx, &tgbotapi.Message{MessageID: 1, Chat: chat, From: from, Text: text, Entities: ents})
+        mc, ok := waitForMessageConfig(api.calls, time.Second)
+        if !ok {
+            t.Fatalf("no reply to %q", text)
+        }
+        return mc
+    }
+
+    // язык из Telegram: ответы, кнопки и задача — по-английски
+    if mc := send("hello"); !strings.HasPrefix(mc.Text, "This doesn't look like a YouTube link") {
+        t.Fatalf("en reply = %q", mc.Text)
+    }
+    mc := send("https://youtu.be/dQw4w9WgXcQ")
+    mk := mc.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+    if mc.Text != "Choose what to download:" || mk.InlineKeyboard[2][0].Text != "Audio MP3" {
+        t.Fatalf("en keyboard = %q %+v", mc.Text, mk.InlineKeyboard)
+    }
+    b.handleCallback(ctx, &tgbotapi.CallbackQuery{ID: "c", From: from, Message: &tgbotapi.Message{MessageID: 2, Chat: chat}, Data: *mk.InlineKeyboard[2][0].CallbackData})
+    if mc, _ := waitForMessageConfig(api.calls, time.Second); mc.Text != "Queued: Audio MP3" {
+        t.Fatalf("queued = %q", mc.Text)
+    }
+    if j := <-jobs; j.Lang != "en" {
+        t.Fatalf("job lang = %q", j.Lang)
+    }
+
+    // /language ru перекрывает язык Telegram, auto возвращает его
+    if mc := send("/language ru"); mc.Text != "Язык: русский." {
+        t.Fatalf("set ru = %q", mc.Text)
+    }
+    if mc := send("hello"); !strings.HasPrefix(mc.Text, "Похоже, это не ссылка") {
+        t.Fatalf("ru reply = %q", mc.Text)
+    }
+    if mc := send("/language de"); !strings.Contains(mc.Text, "en, ru") {
+        t.Fatalf("unknown lang = %q", mc.Text)
+    }
+    if mc := send("/language auto"); mc.Text != "Language: English." {
+        t.Fatalf("auto = %q", mc.Text)
+    }
+}
+
+func TestSettings_MenuAndAutoDownload(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, CmdTimeoutSec: 5, LiveMaxMinutes: 30}
+    q := queue.NewQueue(10, 1)
+    api := newFakeAPI()
+    b := NewBot(api, cfg, state.NewStore(), q, &fakeRunner{dir: cfg.DownloadDir})
+    jobs := make(chan queue.Job, 4)
+    q.Start(ctx, func(_ context.Context, j queue.Job) { jobs <- j })
+
+    chat := &tgbotapi.Chat{ID: 43, Type: "private"}
+    from := &tgbotapi.User{ID: 43}
+    b.handleMessage(ctx, &tgbotapi.Message{MessageID: 1, Chat: chat, From: from, Text: "/settings", Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Length: 9}}})
+    mc, ok := waitForMessageConfig(api.calls, time.Second)
+    if !ok || mc.Text != "Настройки. Нажмите на строку, чтобы изменить:" {
+        t.Fatalf("settings = %q", mc.Text)
+    }
+    menu := mc.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+    press := func(key string) (tgbotapi.CallbackConfig, *tgbotapi.EditMessageTextConfig) {
+        b.handleCallback(ctx, &tgbotapi.CallbackQuery{ID: "c", From: from, Message: &tgbotapi.Message{MessageID: 2, Chat: chat}, Data: "set=" + key})
+        answer := (<-api.reqs).(tgbotapi.CallbackConfig)
+        select {
+        case r := <-api.reqs:
+            edit := r.(tgbotapi.EditMessageTextConfig)
+            return answer, &edit
+        default:
+            return answer, nil
+        }
+    }
+
+    // автозагрузка без пресета по умолчанию не включается
+    if a, edit := press("auto"); a.Text != "Сначала выберите качество по умолчанию." || edit != nil {
+        t.Fatalf("auto without variant = %q %v", a.Text, edit)
+    }
+    if *menu.InlineKeyboard[0][0].CallbackData != "set=variant" || menu.InlineKeyboard[0][0].Text != "Качество по умолчанию: не выбрано" {
+        t.Fatalf("menu = %+v", menu.InlineKeyboard[0])
+    }
+    press("variant")
+    press("variant")
+    press("auto")
+    press("caption")
+    _, edit := press("subs")
+    rows := edit.ReplyMarkup.InlineKeyboard
+    if rows[0][0].Text != "Качество по умолчанию: HD 720p" || rows[1][0].Text != "Сразу качать без кнопок: вкл" ||
+        rows[2][0].Text != "Подписи к файлам: выкл" || rows[3][0].Text != "Субтитры: en" {
+        t.Fatalf("menu after = %+v", rows)
+    }
+
+    // ссылка сразу ставит задачу с настройками пользователя
+    b.handleMessage(ctx, &tgbotapi.Message{MessageID: 3, Chat: chat, From: from, Text: "https://youtu.be/dQw4w9WgXcQ"})
+    if mc, _ := waitForMessageConfig(api.calls, time.Second); mc.Text != "Задача поставлена в очередь: HD 720p" {
+        t.Fatalf("queued = %q", mc.Text)
+    }
+    j := <-jobs
+    if j.Variant != queue.Variant("video720") || j.Subtitles != "en" || !j.NoCaption || j.ReplyTo != 0 {
+        t.Fatalf("job = %+v", j)
+    }
+}
+
+// statusRunner — загрузчик со сводкой для /status
+type statusRunner struct{ *fakeRunner }
+
+func (statusRunner) StatusText(l i18n.Localizer) string { return l.T("status.breaker_closed") }
+
+func TestAdmin_StatusAndNotificationsInAdminLanguage(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, CmdTimeoutSec: 5, Concurrency: 1, AdminIDs: []int64{42, 43}}
+    api := newFakeAPI()
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), statusRunner{&fakeRunner{dir: cfg.DownloadDir}})
+
+    // /status — сводка загрузчика и очереди на языке админа
+    chat := &tgbotapi.Chat{ID: 42, Type: "private"}
+    from := &tgbotapi.User{ID: 42, LanguageCode: "en"}
+    cmd := func(text string) tgbotapi.MessageConfig {
+        b.handleMessage(ctx, &tgbotapi.Message{MessageID: 1, Chat: chat, From: from, Text: text, Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(strings.Fields(text)[0])}}})
+        mc, ok := waitForMessageConfig(api.calls, time.Second)
+        if !ok {
+            t.Fatalf("no reply to %q", text)
+        }
+        return mc
+    }
+    if mc := cmd("/status"); mc.Text != "Circuit breaker: closed\nQueue: 0 jobs, workers: 1" {
+        t.Fatalf("status = %q", mc.Text)
+    }
+
+    // служебное сообщение: каждому админу на его языке (43 не выбирал — язык по умолчанию)
+    cmd("/language en")
+    b.NotifyAdmins(func(l i18n.Localizer) string { return l.T("admin.rate_limited", "kind", "rate_limited", "time", "12:00") })
+    got := map[int64]string{}
+    for i := 0; i < 2; i++ {
+        mc, ok := waitForMessageConfig(api.calls, time.Second)
+        if !ok {
+            t.Fatal("admin notification not sent")
+        }
+        got[mc.ChatID] = mc.Text
+    }
+    if !strings.HasPrefix(got[42], "[admin] YouTube is rate-limiting") || !strings.HasPrefix(got[43], "[admin] YouTube ограничивает") {
+        t.Fatalf("notifications = %q", got)
+    }
+}
+
+func TestWebhook_DeletedBeforeStartReturns(t *testing.T) {
+    t.Parallel()
+    ctx, cancel := context.WithCancel(context.Background())
+    cfg := &config.Config{DownloadDir: t.TempDir(), MaxFileMB: 50, WebhookURL: "https://bot.example", WebhookPath: "/tg", WebhookListen: "127.0.0.1:0", UpdateWorkers: 1}
+    api := newFakeAPI()
+    b := NewBot(api, cfg, state.NewStore(), queue.NewQueue(10, 1), &fakeRunner{dir: cfg.DownloadDir})
+    done := make(chan error, 1)
+    go func() { done <- b.Start(ctx) }()
+    if _, ok := waitForRequest[tgbotapi.WebhookConfig](api.reqs, time.Second); !ok {
+        t.Fatal("setWebhook not called")
+    }
+
+    // main выходит сразу после Start: deleteWebhook должен уйти до возврата
+    cancel()
+    select {
+    case <-done:
+    case <-time.After(10 * time.Second):
+        t.Fatal("Start did not return after cancel")
+    }
+    for {
+        select {
+        case c := <-api.reqs:
+            if _, ok := c.(tgbotapi.DeleteWebhookConfig); ok {
+                return
+            }
+        default:
+            t.Fatal("deleteWebhook was not sent before Start returned")
+        }
+    }
+}
+
 // end
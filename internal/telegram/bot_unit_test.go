@@ -1,8 +1,21 @@
 package telegram
 
 import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
     "testing"
+    "time"
+    "youtube-bot-simple/internal/config"
+    "youtube-bot-simple/internal/downloader"
+    "youtube-bot-simple/internal/i18n"
     "youtube-bot-simple/internal/queue"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func TestExtractYouTubeURL(t *testing.T) {
@@ -15,6 +28,7 @@ func TestExtractYouTubeURL(t *testing.T) {
         {"Check this: https://youtu.be/dQw4w9WgXcQ?t=43s end", "https://youtu.be/dQw4w9WgXcQ?t=43s"},
         {"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
         {"text before https://youtube.com/watch?v=dQw4w9WgXcQ&ab_channel=X text after", "https://youtube.com/watch?v=dQw4w9WgXcQ&ab_channel=X"},
+        {"live: https://www.youtube.com/live/jfKfPfyJRdk?si=x", "https://www.youtube.com/live/jfKfPfyJRdk?si=x"},
         {"no link here", ""},
         {"http://example.com/?q=youtube", ""},
     }
@@ -48,45 +62,657 @@ func TestParseCallbackData(t *testing.T) {
     }
 }
 
-func TestToVariant(t *testing.T) {
+func TestPresetByCode(t *testing.T) {
     t.Parallel()
+    reg := config.DefaultPresets()
     cases := []struct{
         in   string
         want queue.Variant
+        ok   bool
     }{
-        {"360", queue.VarVideo360},
-        {"720", queue.VarVideo720},
-        {"1080", queue.VarVideo1080},
-        {"1440", queue.VarVideo1440},
-        {"mp3", queue.VarAudioMP3},
-        {"unknown", queue.VarVideo360}, // default fallback
-        {"", queue.VarVideo360},
+        {"360", queue.Variant("video360"), true},
+        {"720", queue.Variant("video720"), true},
+        {"1080", queue.Variant("video1080"), true},
+        {"1440", queue.Variant("video1440"), true},
+        {"mp3", queue.Variant("audioMp3"), true},
+        {"unknown", "", false},
+        {"", "", false},
     }
     for i, tc := range cases {
-        got := toVariant(tc.in)
-        if got != tc.want {
-            t.Fatalf("case %d: toVariant(%q) = %q; want %q", i, tc.in, got, tc.want)
+        p, ok := reg.ByCode(tc.in)
+        if ok != tc.ok || queue.Variant(p.ID) != tc.want {
+            t.Fatalf("case %d: ByCode(%q) = (%q,%v); want (%q,%v)", i, tc.in, p.ID, ok, tc.want, tc.ok)
         }
     }
 }
 
-func TestHumanVariant(t *testing.T) {
+func TestPresetLabel(t *testing.T) {
     t.Parallel()
+    reg := config.DefaultPresets()
     cases := []struct{
         in   queue.Variant
         want string
     }{
-        {queue.VarVideo360, "Видео 360p"},
-        {queue.VarVideo720, "HD 720p"},
-        {queue.VarVideo1080, "Full HD 1080p"},
-        {queue.VarVideo1440, "2K 1440p"},
-        {queue.VarAudioMP3, "Аудио MP3"},
+        {queue.Variant("video360"), "Видео 360p"},
+        {queue.Variant("video720"), "HD 720p"},
+        {queue.Variant("video1080"), "Full HD 1080p"},
+        {queue.Variant("video1440"), "2K 1440p"},
+        {queue.Variant("audioMp3"), "Аудио MP3"},
         {queue.Variant("x"), "x"},
     }
     for i, tc := range cases {
-        got := humanVariant(tc.in)
+        got := presetLabel(i18n.Localizer{}, reg, tc.in)
         if got != tc.want {
-            t.Fatalf("case %d: humanVariant(%q) = %q; want %q", i, string(tc.in), got, tc.want)
+            t.Fatalf("case %d: presetLabel(%q) = %q; want %q", i, string(tc.in), got, tc.want)
+        }
+    }
+    // перевод из labels, без него — label
+    en := i18n.Default().For("en")
+    if got := presetLabel(en, reg, queue.Variant("audioMp3")); got != "Audio MP3" {
+        t.Fatalf("en mp3 = %q", got)
+    }
+    if got := presetLabel(en, reg, queue.Variant("video720")); got != "HD 720p" {
+        t.Fatalf("en 720 = %q", got)
+    }
+    // трансляция — не пресет: подпись по длительности записи
+    if got := jobLabel(en, reg, queue.Job{Variant: queue.VarLive, LiveMinutes: 5}); got != "the next 5 minutes" {
+        t.Fatalf("live label = %q", got)
+    }
+}
+
+func TestSendMethod(t *testing.T) {
+    t.Parallel()
+    reg := config.DefaultPresets()
+    cases := []struct{
+        job  queue.Job
+        ext  string
+        want string
+    }{
+        {queue.Job{Variant: queue.Variant("audioMp3")}, "mp3", config.SendAudio},
+        {queue.Job{Variant: queue.Variant("video720")}, "mp4", config.SendVideo},
+        {queue.Job{Variant: queue.Variant("video720")}, "webm", config.SendDocument},
+        {queue.Job{Variant: queue.VarLive, LiveMinutes: 10}, "mp4", config.SendVideo},
+    }
+    for i, tc := range cases {
+        if got := sendMethod(reg, tc.job, tc.ext); got != tc.want {
+            t.Fatalf("case %d: sendMethod = %q; want %q", i, got, tc.want)
+        }
+    }
+}
+
+func TestParseLiveVariant(t *testing.T) {
+    t.Parallel()
+    cases := []struct{
+        in        string
+        minutes   int
+        fromStart bool
+        ok        bool
+    }{
+        {"live10", 10, false, true},
+        {"live60", 30, false, true}, // ограничено LIVE_MAX_MINUTES
+        {"livestart", 30, true, true},
+        {"live0", 0, false, false},
+        {"livex", 0, false, false},
+        {"720", 0, false, false},
+    }
+    for i, tc := range cases {
+        m, fs, ok := parseLiveVariant(tc.in, 30)
+        if m != tc.minutes || fs != tc.fromStart || ok != tc.ok {
+            t.Fatalf("case %d: parseLiveVariant(%q) = (%d,%v,%v); want (%d,%v,%v)", i, tc.in, m, fs, ok, tc.minutes, tc.fromStart, tc.ok)
+        }
+    }
+}
+
+func TestVideoID(t *testing.T) {
+    t.Parallel()
+    ids := map[string]string{
+        "https://youtu.be/dQw4w9WgXcQ?t=10":               "dQw4w9WgXcQ",
+        "https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=1": "dQw4w9WgXcQ",
+        "https://youtube.com/live/abcdef12345":            "abcdef12345",
+    }
+    for in, want := range ids {
+        if got := videoID(in); got != want {
+            t.Fatalf("videoID(%q) = %q; want %q", in, got, want)
+        }
+    }
+}
+
+func TestCallbackFieldSponsor(t *testing.T) {
+    t.Parallel()
+    if callbackField("t=abc;sb=next", "sb") != "next" || callbackField("t=abc;v=360", "sb") != "" {
+        t.Fatalf("callbackField misparsed sb field")
+    }
+}
+
+func TestNextSponsorMode(t *testing.T) {
+    t.Parallel()
+    mode := queue.SponsorOff
+    for _, want := range []string{queue.SponsorRemove, queue.SponsorMark, queue.SponsorOff} {
+        mode = nextSponsorMode(mode)
+        if mode != want {
+            t.Fatalf("nextSponsorMode = %q; want %q", mode, want)
+        }
+    }
+}
+
+func TestSponsorNote(t *testing.T) {
+    t.Parallel()
+    l := i18n.Default().For("en")
+    if got := sponsorNote(l, &DownloadResult{SponsorCut: 30}); got != "" {
+        t.Fatalf("note without removal = %q", got)
+    }
+    if got := sponsorNote(l, &DownloadResult{SponsorRemoved: true}); got != l.T("sponsor.none") {
+        t.Fatalf("note without cut = %q", got)
+    }
+    if got := sponsorNote(l, &DownloadResult{SponsorRemoved: true, SponsorCut: 87.4}); got != "Cut by SponsorBlock: 1:27" {
+        t.Fatalf("note = %q; want cut time 1:27", got)
+    }
+}
+
+// rawAPI — fakeAPI с прямой загрузкой: запоминает параметры sendVideo
+type rawAPI struct {
+    *fakeAPI
+    endpoint string
+    params   tgbotapi.Params
+    files    []tgbotapi.RequestFile
+}
+
+func (r *rawAPI) UploadFiles(endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (*tgbotapi.APIResponse, error) {
+    r.endpoint, r.params, r.files = endpoint, params, files
+    return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func TestSendVideoMetadata(t *testing.T) {
+    t.Parallel()
+    res := &DownloadResult{Path: "/tmp/x.mp4", Width: 1080, Height: 1920, Duration: 58.6, Thumb: "/tmp/x.thumb.jpg"}
+
+    // с прямой загрузкой — размеры, длительность, превью и стриминг
+    api := &rawAPI{fakeAPI: newFakeAPI()}
+    b := &Bot{api: api, cfg: &config.Config{}}
+    if _, err := b.sendVideo(1, 0, 0, res, "cap", ""); err != nil {
+        t.Fatal(err)
+    }
+    p := api.params
+    if api.endpoint != "sendVideo" || p["width"] != "1080" || p["height"] != "1920" || p["duration"] != "59" || p["supports_streaming"] != "true" || len(api.files) != 2 {
+        t.Fatalf("sendVideo params = %v files=%d", p, len(api.files))
+    }
+
+    // без неё — VideoConfig с тем, что библиотека умеет
+    plain := newFakeAPI()
+    b = &Bot{api: plain, cfg: &config.Config{}}
+    if _, err := b.sendVideo(1, 0, 0, res, "cap", ""); err != nil {
+        t.Fatal(err)
+    }
+    v, ok := (<-plain.calls).(tgbotapi.VideoConfig)
+    if !ok || v.Duration != 59 || !v.SupportsStreaming || v.Thumb == nil {
+        t.Fatalf("VideoConfig = %+v", v)
+    }
+}
+
+func TestSendToForumTopic(t *testing.T) {
+    t.Parallel()
+    res := &DownloadResult{Path: "/tmp/x.mp3", Duration: 60, Title: "T", Uploader: "U"}
+    api := &rawAPI{fakeAPI: newFakeAPI()}
+    b := &Bot{api: api, cfg: &config.Config{}}
+
+    // тема форума — только прямым вызовом, у любого способа отправки
+    for _, send := range []struct {
+        endpoint, field string
+        fn              func() (tgbotapi.Message, error)
+    }{
+        {"sendAudio", "audio", func() (tgbotapi.Message, error) { return b.sendAudio(-100, 7, 3, res, "cap", "") }},
+        {"sendDocument", "document", func() (tgbotapi.Message, error) { return b.sendDocument(-100, 7, 3, res, "cap", "") }},
+        {"sendVideo", "video", func() (tgbotapi.Message, error) { return b.sendVideo(-100, 7, 3, res, "cap", "") }},
+    } {
+        if _, err := send.fn(); err != nil {
+            t.Fatal(err)
+        }
+        p := api.params
+        if api.endpoint != send.endpoint || p["message_thread_id"] != "7" || p["reply_to_message_id"] != "3" || p["allow_sending_without_reply"] != "true" || api.files[0].Name != send.field {
+            t.Fatalf("%s params = %v", send.endpoint, p)
+        }
+    }
+
+    b.replyIn(-100, 7, "hi", 3)
+    if api.endpoint != "sendMessage" || api.params["message_thread_id"] != "7" || api.params["text"] != "hi" {
+        t.Fatalf("sendMessage params = %v", api.params)
+    }
+
+    // без темы аудио уходит обычным конфигом
+    if _, err := b.sendAudio(-100, 0, 3, res, "cap", ""); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := (<-api.calls).(tgbotapi.AudioConfig); !ok {
+        t.Fatal("audio without topic should use AudioConfig")
+    }
+}
+
+func TestThreadsFromRawUpdate(t *testing.T) {
+    t.Parallel()
+    th := newThreads()
+    cases := []struct {
+        raw  string
+        want int
+    }{
+        {`{"update_id":1,"message":{"message_id":5,"message_thread_id":7,"is_topic_message":true}}`, 7},
+        {`{"update_id":2,"callback_query":{"id":"c","message":{"message_id":6,"message_thread_id":9,"is_topic_message":true}}}`, 9},
+        // ветка ответов в обычной супергруппе — не тема
+        {`{"update_id":3,"message":{"message_id":8,"message_thread_id":4}}`, 0},
+        {`{"update_id":4,"inline_query":{"id":"q"}}`, 0},
+    }
+    for _, c := range cases {
+        id := th.record([]byte(c.raw))
+        if got := th.take(id); got != c.want {
+            t.Fatalf("%s: thread = %d; want %d", c.raw, got, c.want)
+        }
+        if th.take(id) != 0 {
+            t.Fatalf("%s: thread must be taken once", c.raw)
+        }
+    }
+    var none *threads
+    if none.record([]byte(cases[0].raw)) != 1 || none.take(1) != 0 {
+        t.Fatal("nil threads must only report update_id")
+    }
+}
+
+func TestCaptionEscaping(t *testing.T) {
+    t.Parallel()
+    if got := escapeCaption("a<b>&c", "HTML"); got != "a&lt;b&gt;&amp;c" {
+        t.Fatalf("HTML escape = %q", got)
+    }
+    if got := escapeCaption("v1.2 (live)!", "MarkdownV2"); got != `v1\.2 \(live\)\!` {
+        t.Fatalf("MarkdownV2 escape = %q", got)
+    }
+    if got := captionLink("A_b", "https://x.io/a)b", "MarkdownV2"); got != `[A\_b](https://x.io/a\)b)` {
+        t.Fatalf("MarkdownV2 link = %q", got)
+    }
+    if n := visibleLen(`<a href="https://x.io">A&amp;B</a>`, "HTML"); n != 3 {
+        t.Fatalf("visibleLen HTML = %d", n)
+    }
+    if n := visibleLen(`[A\_b](https://x.io) \.`, "MarkdownV2"); n != 5 {
+        t.Fatalf("visibleLen MarkdownV2 = %d", n)
+    }
+}
+
+func TestCaptionRender(t *testing.T) {
+    t.Parallel()
+    res := &DownloadResult{Title: "Tom & Jerry", Uploader: "MGM", Duration: 201, Width: 1920, Height: 1080, Size: 12900000,
+        Chapters: []downloader.Chapter{{Start: 0, Title: "Intro"}, {Start: 65, Title: "Chase"}}}
+    f := captionFields{title: res.Title, url: "https://youtu.be/abc", sponsor: "Вырезано SponsorBlock: 0:30", res: res}
+    f.chapters = []string{"0:00 Intro", "1:05 Chase"}
+
+    got, err := fitCaption(DefaultCaptionTemplate, "HTML", f)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := "<a href=\"https://youtu.be/abc\">Tom &amp; Jerry</a>\nMGM · 3:21 · 1920×1080 · 12.3 МБ\nВырезано SponsorBlock: 0:30\n\n0:00 Intro\n1:05 Chase"
+    if got != want {
+        t.Fatalf("caption = %q; want %q", got, want)
+    }
+
+    // MarkdownV2: точка в размере и текст самого шаблона экранируются
+    got, err = fitCaption(DefaultCaptionTemplate, "MarkdownV2", f)
+    want = "[Tom & Jerry](https://youtu.be/abc)\nMGM · 3:21 · 1920×1080 · 12\\.3 МБ\nВырезано SponsorBlock: 0:30\n\n0:00 Intro\n1:05 Chase"
+    if err != nil || got != want {
+        t.Fatalf("MarkdownV2 caption = %q, %v; want %q", got, err, want)
+    }
+    if got, err := fitCaption("Done! {title} ({size})", "MarkdownV2", f); err != nil || got != `Done\! Tom & Jerry \(12\.3 МБ\)` {
+        t.Fatalf("MarkdownV2 template text = %q, %v", got, err)
+    }
+    if got, err := fitCaption("a & b <{title}>", "HTML", f); err != nil || got != "a &amp; b &lt;Tom &amp; Jerry&gt;" {
+        t.Fatalf("HTML template text = %q, %v", got, err)
+    }
+
+    // пользовательский шаблон без разметки; строка только из пустых полей выпадает
+    got, err = fitCaption("{title} [{resolution}]\n{sponsorblock}\n\n\n{url}", "plain", captionFields{title: res.Title, url: f.url, res: res})
+    if err != nil || got != "Tom & Jerry [1920×1080]\n\nhttps://youtu.be/abc" {
+        t.Fatalf("plain caption = %q, %v", got, err)
+    }
+    // неизвестные поля и шаблоны Go (с циклами) не принимаются
+    for _, tmpl := range []string{"{nope}", "{{range 1000000000}}{{.Title}}{{end}}"} {
+        if _, err := fitCaption(tmpl, "HTML", f); err == nil {
+            t.Fatalf("fitCaption(%q) accepted", tmpl)
+        }
+    }
+    // фигурные скобки без имени поля — обычный текст
+    if got, err := fitCaption("{title} {1}", "plain", f); err != nil || got != "Tom & Jerry {1}" {
+        t.Fatalf("literal braces = %q, %v", got, err)
+    }
+}
+
+func TestCaptionTruncation(t *testing.T) {
+    t.Parallel()
+    res := &DownloadResult{Title: strings.Repeat("я", 2000), Uploader: "ch"}
+    f := captionFields{title: res.Title, url: "https://youtu.be/abc", res: res}
+    for i := 0; i < 200; i++ {
+        f.chapters = append(f.chapters, "0:00 глава")
+    }
+    for _, mode := range []string{"HTML", "MarkdownV2", "plain"} {
+        got, err := fitCaption(DefaultCaptionTemplate, mode, f)
+        if err != nil {
+            t.Fatalf("%s: %v", mode, err)
+        }
+        if n := visibleLen(got, mode); n > captionLimit {
+            t.Fatalf("%s: visible length %d > %d", mode, n, captionLimit)
+        }
+        if strings.Contains(got, "глава") {
+            t.Fatalf("%s: chapters should be dropped first", mode)
+        }
+    }
+    // шаблон длиннее лимита с разметкой — ошибка, вызывающий откатится на текст без разметки
+    if _, err := fitCaption(strings.Repeat("x", 2000), "HTML", f); err == nil {
+        t.Fatal("expected overflow error")
+    }
+}
+
+func TestWebhookHandler(t *testing.T) {
+    t.Parallel()
+    wh := NewWebhook("/tg/secretpath", "s3cret", 1)
+    post := func(path, secret, body string) int {
+        r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+        if secret != "" {
+            r.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+        }
+        w := httptest.NewRecorder()
+        wh.ServeHTTP(w, r)
+        return w.Code
+    }
+    upd := `{"update_id":7,"message":{"message_id":1,"chat":{"id":5},"text":"hi"}}`
+
+    if code := post("/other", "s3cret", upd); code != http.StatusNotFound {
+        t.Fatalf("wrong path: %d", code)
+    }
+    if code := post("/tg/secretpath", "bad", upd); code != http.StatusForbidden {
+        t.Fatalf("bad secret: %d", code)
+    }
+    if code := post("/tg/secretpath", "s3cret", "{"); code != http.StatusBadRequest {
+        t.Fatalf("bad body: %d", code)
+    }
+    if code := post("/tg/secretpath", "s3cret", upd); code != http.StatusOK {
+        t.Fatalf("valid update: %d", code)
+    }
+    // буфер полон — 503, Telegram пришлёт повторно
+    if code := post("/tg/secretpath", "s3cret", upd); code != http.StatusServiceUnavailable {
+        t.Fatalf("full buffer: %d", code)
+    }
+    if u := <-wh.Updates(); u.UpdateID != 7 || u.Message == nil || u.Message.Text != "hi" {
+        t.Fatalf("update = %+v", u)
+    }
+}
+
+func TestSetWebhookParams(t *testing.T) {
+    t.Parallel()
+    api := &rawAPI{fakeAPI: newFakeAPI()}
+    cfg := &config.Config{WebhookURL: "https://bot.example.com", WebhookPath: "/tg/abc", WebhookSecret: "s3cret"}
+    b := &Bot{api: api, cfg: cfg}
+    if err := b.setWebhook(); err != nil {
+        t.Fatal(err)
+    }
+    p := api.params
+    if api.endpoint != "setWebhook" || p["url"] != "https://bot.example.com/tg/abc" || p["secret_token"] != "s3cret" || len(api.files) != 0 {
+        t.Fatalf("setWebhook params = %v files=%d", p, len(api.files))
+    }
+}
+
+// flakyAPI — fakeAPI, который первые вызовы проваливает заданными ошибками
+type flakyAPI struct {
+    *fakeAPI
+    errs  []error
+    tries int
+}
+
+func (f *flakyAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+    f.tries++
+    if len(f.errs) > 0 {
+        err := f.errs[0]
+        f.errs = f.errs[1:]
+        return tgbotapi.Message{}, err
+    }
+    return f.fakeAPI.Send(c)
+}
+
+func TestOutboxPacingAndRetries(t *testing.T) {
+    t.Parallel()
+    // сон не идёт, только записывается, поэтому ожидание копится
+    newOutbox := func(api Sender, chatPerSec int, slept *[]time.Duration) *Outbox {
+        o := NewOutbox(context.Background(), api, 0, chatPerSec, 20).(*Outbox)
+        o.sleep = func(_ context.Context, d time.Duration) error { *slept = append(*slept, d); return nil }
+        return o
+    }
+
+    t.Run("private chat", func(t *testing.T) {
+        t.Parallel()
+        // личный чат — не чаще раза в секунду
+        var slept []time.Duration
+        o := newOutbox(newFakeAPI(), 1, &slept)
+        for i := 0; i < 3; i++ {
+            if _, err := o.Send(tgbotapi.NewMessage(1, "x")); err != nil {
+                t.Fatal(err)
+            }
+        }
+        if len(slept) != 2 || slept[0] < 900*time.Millisecond || slept[1] < 1900*time.Millisecond {
+            t.Fatalf("private chat waits = %v", slept)
         }
+    })
+
+    t.Run("group", func(t *testing.T) {
+        t.Parallel()
+        // группа — 20 в минуту, то есть раз в 3 секунды
+        var slept []time.Duration
+        o := newOutbox(newFakeAPI(), 1, &slept)
+        _, _ = o.Send(tgbotapi.NewMessage(-5, "x"))
+        _, _ = o.Send(tgbotapi.NewMessage(-5, "x"))
+        if len(slept) != 1 || slept[0] < 2900*time.Millisecond {
+            t.Fatalf("group waits = %v", slept)
+        }
+    })
+
+    t.Run("retry after", func(t *testing.T) {
+        t.Parallel()
+        // 429 — ждём retry_after и повторяем
+        var slept []time.Duration
+        flood := &flakyAPI{fakeAPI: newFakeAPI(), errs: []error{&tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}}}}
+        o := newOutbox(flood, 1, &slept)
+        if _, err := o.Send(tgbotapi.NewMessage(2, "x")); err != nil || flood.tries != 2 {
+            t.Fatalf("429 retry: err=%v tries=%d", err, flood.tries)
+        }
+        if len(slept) != 1 || slept[0] < 6*time.Second {
+            t.Fatalf("retry_after wait = %v", slept)
+        }
+    })
+
+    t.Run("upload retry", func(t *testing.T) {
+        t.Parallel()
+        // сбой сети при загрузке — повтор с паузой; ошибка запроса — сразу наружу
+        var slept []time.Duration
+        upload := &flakyAPI{fakeAPI: newFakeAPI(), errs: []error{errors.New("connection reset"), errors.New("connection reset")}}
+        o := newOutbox(upload, 0, &slept) // без темпа чата — в slept только паузы повтора
+        if _, err := o.Send(tgbotapi.NewVideo(3, tgbotapi.FilePath("/tmp/x.mp4"))); err != nil || upload.tries != 3 {
+            t.Fatalf("upload retry: err=%v tries=%d", err, upload.tries)
+        }
+        if len(slept) != 2 || slept[0] != time.Second || slept[1] != 2*time.Second {
+            t.Fatalf("upload backoff = %v", slept)
+        }
+        bad := &flakyAPI{fakeAPI: newFakeAPI(), errs: []error{&tgbotapi.Error{Code: 400, Message: "Bad Request"}}}
+        o = newOutbox(bad, 0, &slept)
+        if _, err := o.Send(tgbotapi.NewVideo(4, tgbotapi.FilePath("/tmp/x.mp4"))); err == nil || bad.tries != 1 {
+            t.Fatalf("bad request should not retry: err=%v tries=%d", err, bad.tries)
+        }
+    })
+
+    t.Run("cancel", func(t *testing.T) {
+        t.Parallel()
+        // остановка бота прерывает ожидание retry_after и очереди чата
+        ctx, cancel := context.WithCancel(context.Background())
+        flood := &flakyAPI{fakeAPI: newFakeAPI(), errs: []error{&tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 60}}}}
+        o := NewOutbox(ctx, flood, 0, 1, 20)
+        done := make(chan error, 1)
+        go func() {
+            _, err := o.Send(tgbotapi.NewMessage(2, "x"))
+            done <- err
+        }()
+        time.Sleep(50 * time.Millisecond)
+        cancel()
+        select {
+        case err := <-done:
+            if !errors.Is(err, context.Canceled) || flood.tries != 1 {
+                t.Fatalf("cancelled send: err=%v tries=%d", err, flood.tries)
+            }
+        case <-time.After(time.Second):
+            t.Fatal("send kept waiting after cancel")
+        }
+    })
+
+    t.Run("raw uploader", func(t *testing.T) {
+        t.Parallel()
+        // обёртка сохраняет прямые вызовы, только если их умеет API
+        if _, ok := NewOutbox(context.Background(), &rawAPI{fakeAPI: newFakeAPI()}, 25, 1, 20).(RawUploader); !ok {
+            t.Fatal("outbox over raw API should be RawUploader")
+        }
+        if _, ok := NewOutbox(context.Background(), newFakeAPI(), 25, 1, 20).(RawUploader); ok {
+            t.Fatal("outbox over plain API should not be RawUploader")
+        }
+    })
+}
+
+func TestDispatcherPerChatOrder(t *testing.T) {
+    t.Parallel()
+    d := newDispatcher(2)
+    defer d.stop()
+    release := make(chan struct{})
+    var mu sync.Mutex
+    var order []string
+    add := func(s string) { mu.Lock(); order = append(order, s); mu.Unlock() }
+
+    // чат 1 занят медленной обработкой, следующая в нём ждёт; чат 2 идёт параллельно
+    d.submit(1, func() { <-release; add("1a") })
+    d.submit(1, func() { add("1b") })
+    done2 := make(chan struct{})
+    d.submit(2, func() { add("2"); close(done2) })
+    select {
+    case <-done2:
+    case <-time.After(time.Second):
+        t.Fatal("chat 2 blocked by slow chat 1")
+    }
+
+    // паника не останавливает очередь чата
+    d.submit(1, func() { panic("boom") })
+    d.submit(1, func() { add("1c") })
+    close(release)
+    d.wait()
+
+    if got := strings.Join(order, ","); got != "2,1a,1b,1c" {
+        t.Fatalf("order = %s", got)
+    }
+}
+
+func TestDispatcherPoolAndQueueLimit(t *testing.T) {
+    t.Parallel()
+    d := newDispatcher(1)
+    defer d.stop()
+    release := make(chan struct{})
+    var mu sync.Mutex
+    var order []string
+    add := func(s string) { mu.Lock(); order = append(order, s); mu.Unlock() }
+
+    // один обработчик: чат 1 занял его, остальное ждёт в очередях, а не в горутинах
+    d.submit(1, func() { <-release; add("1") })
+    for i := 0; i < chatQueueLimit; i++ {
+        if !d.submit(2, func() { add("2") }) {
+            t.Fatalf("update %d rejected below the limit", i)
+        }
+    }
+    if d.submit(2, func() { add("2") }) {
+        t.Fatal("update over the chat limit accepted")
+    }
+    // переполнение одного чата не мешает другим
+    if !d.submit(3, func() { add("3") }) {
+        t.Fatal("other chat rejected")
+    }
+    close(release)
+    d.wait()
+
+    // чаты чередуются: после первого обновления чат 2 уходит в конец очереди
+    if len(order) != chatQueueLimit+2 || order[0] != "1" || order[1] != "2" || order[2] != "3" {
+        t.Fatalf("order = %v", order)
+    }
+}
+
+func TestRouterMatchingAndMiddleware(t *testing.T) {
+    t.Parallel()
+    r := NewRouter()
+    var trace []string
+    mw := func(name string) Middleware {
+        return func(next HandlerFunc) HandlerFunc {
+            return func(u *Update) { trace = append(trace, name); next(u) }
+        }
+    }
+    r.Use(mw("outer"), mw("inner"))
+    r.Command("help", func(u *Update) { trace = append(trace, "help:"+u.Args) })
+    r.Text(func(u *Update) bool { return strings.Contains(u.Message.Text, "youtu") }, func(u *Update) { trace = append(trace, "link") })
+    r.Fallback(func(u *Update) { trace = append(trace, "fallback:"+u.Command) })
+    r.Callback("sb", func(u *Update) { trace = append(trace, "sb") })
+    r.Callback("", func(u *Update) { trace = append(trace, "button") })
+
+    command := func(text string) tgbotapi.Update {
+        cmd := strings.Fields(text)[0]
+        return tgbotapi.Update{Message: &tgbotapi.Message{Text: text, Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(cmd)}}}}
+    }
+    cases := []struct {
+        upd  tgbotapi.Update
+        want string
+    }{
+        {command("/Help@bot me"), "outer,inner,help:me"},
+        {command("/unknown https://youtu.be/abcdefgh"), "outer,inner,link"},
+        {command("/unknown"), "outer,inner,fallback:"},
+        {tgbotapi.Update{Message: &tgbotapi.Message{Text: "hi"}}, "outer,inner,fallback:"},
+        {tgbotapi.Update{Message: &tgbotapi.Message{Text: "  "}}, ""},
+        {tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "t=abc;sb=next"}}, "outer,inner,sb"},
+        {tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "t=abc;v=720"}}, "outer,inner,button"},
+        {tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{}}, ""},
+    }
+    for _, c := range cases {
+        trace = nil
+        r.Handle(context.Background(), c.upd, 0)
+        if got := strings.Join(trace, ","); got != c.want {
+            t.Errorf("%+v: trace = %q, want %q", c.upd, got, c.want)
+        }
+    }
+}
+
+func TestCommandRegistryHelpAndMenu(t *testing.T) {
+    t.Parallel()
+    api := newFakeAPI()
+    b := NewBot(api, &config.Config{AdminIDs: []int64{7}, MaxFileMB: 45}, nil, nil, nil)
+
+    names := func(cmds []Command) string {
+        var out []string
+        for _, c := range cmds { out = append(out, c.Name) }
+        return strings.Join(out, ",")
+    }
+    if got := names(b.commands.Visible(false, false)); got != "start,help,sponsorblock,caption,settings,language" {
+        t.Fatalf("private = %s", got)
+    }
+    if got := names(b.commands.Visible(true, true)); got != "start,help,sponsorblock,caption,group,language,status" {
+        t.Fatalf("group admin = %s", got)
+    }
+
+    help := b.helpText(i18n.Localizer{}, false, false)
+    if !strings.Contains(help, "~45 МБ") || !strings.Contains(help, "/caption [шаблон|reset] — ") || strings.Contains(help, "/status") {
+        t.Fatalf("help = %q", help)
+    }
+
+    // личные чаты, группы и чат администратора — по умолчанию и по-английски
+    b.publishCommands()
+    var got []string
+    for len(api.reqs) > 0 {
+        c := (<-api.reqs).(tgbotapi.SetMyCommandsConfig)
+        got = append(got, fmt.Sprintf("%s/%d/%s:%d:%s", c.Scope.Type, c.Scope.ChatID, c.LanguageCode, len(c.Commands), c.Commands[0].Description))
+    }
+    want := "all_private_chats/0/:6:Начать работу all_private_chats/0/en:6:Get started " +
+        "all_group_chats/0/:6:Начать работу all_group_chats/0/en:6:Get started " +
+        "chat/7/:7:Начать работу chat/7/en:7:Get started"
+    if strings.Join(got, " ") != want {
+        t.Fatalf("menus = %v", got)
     }
 }
@@ -0,0 +1,275 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// captionLimit — лимит подписи Telegram, символов видимого текста
+const captionLimit = 1024
+
+// сколько глав показывать в подписи
+const captionChapters = 10
+
+// DefaultCaptionTemplate — ссылка с названием, строка сведений, заметка SponsorBlock и главы
+const DefaultCaptionTemplate = "{link}\n{info}\n{sponsorblock}\n\n{chapters}"
+
+// captionFields — исходные (не экранированные) значения для шаблона
+type captionFields struct {
+	title, url, sponsor string
+	chapters            []string
+	res                 *DownloadResult
+	l                   i18n.Localizer // единицы размера
+}
+
+// caption — подпись к файлу по шаблону чата (или общему) и parse mode для отправки
+func (b *Bot) caption(l i18n.Localizer, chatID int64, url, sponsor string, res *DownloadResult) (text, parseMode string) {
+	mode := b.cfg.CaptionParseMode
+	tmpl := b.prefs.Get(chatID).Caption
+	if tmpl == "" {
+		tmpl = b.captionTemplate()
+	}
+	f := captionFields{title: res.Title, url: url, sponsor: sponsor, res: res, l: l}
+	for i, c := range res.Chapters {
+		if i == captionChapters {
+			f.chapters = append(f.chapters, l.N("caption.more", len(res.Chapters)-i))
+			break
+		}
+		f.chapters = append(f.chapters, formatClock(time.Duration(c.Start*float64(time.Second)))+" "+c.Title)
+	}
+
+	if mode == "plain" {
+		mode = ""
+	}
+	text, err := fitCaption(tmpl, mode, f)
+	if err != nil && tmpl != DefaultCaptionTemplate {
+		// в том числе шаблон старого формата {{.Title}}, сохранённый до перехода на поля
+		log.Printf("[bot] caption template failed, using default: %v", err)
+		text, err = fitCaption(DefaultCaptionTemplate, mode, f)
+	}
+	if err != nil {
+		// последний вариант — обрезанный текст без разметки
+		text, _ = fitCaption(DefaultCaptionTemplate, "", f)
+		mode = ""
+	}
+	return text, mode
+}
+
+// captionTemplate — общий шаблон из CAPTION_TEMPLATE или встроенный
+func (b *Bot) captionTemplate() string {
+	if b.cfg.CaptionTemplate != "" {
+		return b.cfg.CaptionTemplate
+	}
+	return DefaultCaptionTemplate
+}
+
+// fitCaption — отрисовать и уложить в лимит: сначала убираем главы, затем укорачиваем название
+func fitCaption(tmpl, mode string, f captionFields) (string, error) {
+	if err := config.CheckCaption(tmpl); err != nil {
+		return "", err
+	}
+	text := renderCaption(tmpl, mode, f)
+	if visibleLen(text, mode) <= captionLimit {
+		return text, nil
+	}
+	f.chapters = nil
+	if text = renderCaption(tmpl, mode, f); visibleLen(text, mode) <= captionLimit {
+		return text, nil
+	}
+	over := visibleLen(text, mode) - captionLimit
+	if n := utf8.RuneCountInString(f.title) - over - 1; n > 0 {
+		f.title = truncateText(f.title, n)
+		if text = renderCaption(tmpl, mode, f); visibleLen(text, mode) <= captionLimit {
+			return text, nil
+		}
+	}
+	// длинный сам шаблон — режем текст без разметки, чтобы не сломать теги
+	if mode == "" || mode == "plain" {
+		return truncateText(text, captionLimit-1), nil
+	}
+	return "", fmt.Errorf("caption exceeds %d characters even without chapters", captionLimit)
+}
+
+// renderCaption — подставить поля; строка, где были только пустые поля, убирается
+func renderCaption(tmpl, mode string, f captionFields) string {
+	res := f.res
+	v := map[string]string{
+		"title":        escapeCaption(f.title, mode),
+		"channel":      escapeCaption(res.Uploader, mode),
+		"url":          escapeCaption(f.url, mode),
+		"sponsorblock": escapeCaption(f.sponsor, mode),
+		"chapters":     escapeCaption(strings.Join(f.chapters, "\n"), mode),
+		"link":         captionLink(f.title, f.url, mode),
+	}
+	if res.Duration > 0 {
+		v["duration"] = escapeCaption(formatClock(time.Duration(res.Duration*float64(time.Second))), mode)
+	}
+	if res.Width > 0 && res.Height > 0 {
+		v["resolution"] = escapeCaption(fmt.Sprintf("%d×%d", res.Width, res.Height), mode)
+	}
+	if res.Size > 0 {
+		// «12.3 МБ»: точка в MarkdownV2 без экранирования ломает всю подпись
+		v["size"] = escapeCaption(humanSize(f.l, res.Size), mode)
+	}
+	var info []string
+	for _, k := range []string{"channel", "duration", "resolution", "size"} {
+		if v[k] != "" {
+			info = append(info, v[k])
+		}
+	}
+	v["info"] = strings.Join(info, " · ")
+
+	// текст шаблона между полями — тоже обычный текст: экранируется, как и значения
+	lines := strings.Split(tmpl, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		var sb strings.Builder
+		fields := config.CaptionFieldRe.FindAllStringSubmatchIndex(line, -1)
+		last := 0
+		for _, m := range fields {
+			sb.WriteString(escapeCaption(line[last:m[0]], mode))
+			sb.WriteString(v[line[m[2]:m[3]]])
+			last = m[1]
+		}
+		sb.WriteString(escapeCaption(line[last:], mode))
+		filled := sb.String()
+		if len(fields) > 0 && strings.TrimSpace(filled) == "" {
+			continue
+		}
+		out = append(out, filled)
+	}
+	text := blankLinesRe.ReplaceAllString(strings.Join(out, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// blankLinesRe — подряд идущие пустые строки после выпавших полей
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// captionLink — название со ссылкой; без названия — сама ссылка
+func captionLink(title, url, mode string) string {
+	if title == "" {
+		return escapeCaption(url, mode)
+	}
+	switch mode {
+	case "HTML":
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(title))
+	case "MarkdownV2":
+		// внутри (...) экранируются только ) и \
+		u := strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(url)
+		return "[" + escapeCaption(title, mode) + "](" + u + ")"
+	default:
+		return title + "\n" + url
+	}
+}
+
+var mdV2Escaper = strings.NewReplacer(
+	`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `~`, `\~`, "`", "\\`",
+	`>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+)
+
+// escapeCaption — экранирование значения под parse mode Telegram
+func escapeCaption(s, mode string) string {
+	switch mode {
+	case "HTML":
+		return html.EscapeString(s)
+	case "MarkdownV2":
+		return mdV2Escaper.Replace(s)
+	default:
+		return s
+	}
+}
+
+var (
+	htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+	mdURLRe   = regexp.MustCompile(`\]\((?:\\.|[^)\\])*\)`)
+)
+
+// visibleLen — длина подписи так, как её считает Telegram: без разметки и экранирования
+func visibleLen(s, mode string) int {
+	switch mode {
+	case "HTML":
+		return utf8.RuneCountInString(html.UnescapeString(htmlTagRe.ReplaceAllString(s, "")))
+	case "MarkdownV2":
+		s = mdURLRe.ReplaceAllString(s, "]")
+		n, escaped := 0, false
+		for _, r := range s {
+			switch {
+			case escaped:
+				n++
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case strings.ContainsRune("*_~|`[]", r):
+			default:
+				n++
+			}
+		}
+		return n
+	default:
+		return utf8.RuneCountInString(s)
+	}
+}
+
+// humanSize — «12.3 МБ»
+func humanSize(l i18n.Localizer, n int64) string {
+	switch {
+	case n >= 1<<30:
+		return l.T("size.gb", "n", fmt.Sprintf("%.1f", float64(n)/(1<<30)))
+	case n >= 1<<20:
+		return l.T("size.mb", "n", fmt.Sprintf("%.1f", float64(n)/(1<<20)))
+	default:
+		return l.T("size.kb", "n", (n+1023)/1024)
+	}
+}
+
+// handleCaptionCommand — /caption [шаблон|reset]: шаблон подписи для этого чата
+func (b *Bot) handleCaptionCommand(m *tgbotapi.Message) {
+	l := b.loc(m.From)
+	arg := strings.TrimSpace(m.CommandArguments())
+	// в группах шаблон меняют только администраторы
+	if arg != "" && isGroup(m.Chat) && !b.chatAdmin(m.Chat.ID, m.From) {
+		b.reply(m.Chat.ID, l.T("caption.admins"), m.MessageID)
+		return
+	}
+	switch arg {
+	case "":
+		cur := b.prefs.Get(m.Chat.ID).Caption
+		if cur == "" {
+			cur = b.captionTemplate()
+		}
+		fields := make([]string, len(config.CaptionFields))
+		for i, f := range config.CaptionFields {
+			fields[i] = "{" + f + "}"
+		}
+		b.reply(m.Chat.ID, l.T("caption.current", "mode", b.cfg.CaptionParseMode, "template", cur, "fields", strings.Join(fields, " ")), m.MessageID)
+		return
+	case "reset":
+		arg = ""
+	default:
+		// проверка на примере: синтаксис и итоговая длина
+		sample := &DownloadResult{Title: "Sample", Uploader: "Channel", Duration: 61, Width: 1280, Height: 720, Size: 1 << 20}
+		if _, err := fitCaption(arg, b.cfg.CaptionParseMode, captionFields{title: sample.Title, url: "https://youtu.be/x", res: sample}); err != nil {
+			b.reply(m.Chat.ID, l.T("caption.invalid", "err", err), m.MessageID)
+			return
+		}
+	}
+	if err := b.prefs.Update(m.Chat.ID, func(u *state.UserPrefs) { u.Caption = arg }); err != nil {
+		log.Printf("[bot] save prefs failed: %v", err)
+	}
+	if arg == "" {
+		b.reply(m.Chat.ID, l.T("caption.reset"), m.MessageID)
+		return
+	}
+	b.reply(m.Chat.ID, l.T("caption.saved"), m.MessageID)
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"log"
+	"strings"
+
+	"youtube-bot-simple/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Scope — где команда доступна и видна в меню
+type Scope uint8
+
+const (
+	ScopePrivate Scope = 1 << iota // личный чат
+	ScopeGroup                     // группы и супергруппы
+	ScopeAdmin                     // только ADMIN_IDS
+)
+
+// Command — команда бота: из одного описания строятся маршрут, /help и меню Telegram
+type Command struct {
+	Name string
+	// ключи каталога сообщений: описание и подсказка к аргументам («off|remove|mark [категории]»)
+	Description string
+	Args        string
+	Scope       Scope
+	Handler     HandlerFunc
+}
+
+// Commands — реестр команд в порядке показа
+type Commands struct {
+	list []Command
+}
+
+func (r *Commands) Add(c ...Command) { r.list = append(r.list, c...) }
+
+func (r *Commands) All() []Command { return r.list }
+
+// Visible — команды, доступные в чате этого типа; admin — пользователь из ADMIN_IDS
+func (r *Commands) Visible(group, admin bool) []Command {
+	want := ScopePrivate
+	if group {
+		want = ScopeGroup
+	}
+	var out []Command
+	for _, c := range r.list {
+		if c.Scope&want == 0 || (c.Scope&ScopeAdmin != 0 && !admin) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// menu — список для setMyCommands
+func menu(l i18n.Localizer, cmds []Command) []tgbotapi.BotCommand {
+	out := make([]tgbotapi.BotCommand, 0, len(cmds))
+	for _, c := range cmds {
+		out = append(out, tgbotapi.BotCommand{Command: c.Name, Description: l.T(c.Description)})
+	}
+	return out
+}
+
+// commandList — все команды бота
+func (b *Bot) commandList() *Commands {
+	r := &Commands{}
+	r.Add(
+		Command{Name: "start", Description: "cmd.start", Scope: ScopePrivate | ScopeGroup, Handler: b.onStart},
+		Command{Name: "help", Description: "cmd.help", Scope: ScopePrivate | ScopeGroup, Handler: b.onHelp},
+		Command{Name: "sponsorblock", Description: "cmd.sponsorblock", Args: "cmd.sponsorblock.args", Scope: ScopePrivate | ScopeGroup, Handler: message(b.handleSponsorCommand)},
+		Command{Name: "caption", Description: "cmd.caption", Args: "cmd.caption.args", Scope: ScopePrivate | ScopeGroup, Handler: message(b.handleCaptionCommand)},
+		Command{Name: "group", Description: "cmd.group", Args: "cmd.group.args", Scope: ScopeGroup, Handler: message(b.handleGroupCommand)},
+		Command{Name: "settings", Description: "cmd.settings", Scope: ScopePrivate, Handler: message(b.handleSettingsCommand)},
+		Command{Name: "language", Description: "cmd.language", Args: "cmd.language.args", Scope: ScopePrivate | ScopeGroup, Handler: message(b.handleLanguageCommand)},
+		Command{Name: "status", Description: "cmd.status", Scope: ScopePrivate | ScopeGroup | ScopeAdmin, Handler: b.onStatus},
+	)
+	return r
+}
+
+// inScope — команда вызвана там, где она доступна; иначе — объяснение вместо молчания
+func (b *Bot) inScope(c Command) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(u *Update) {
+			m := u.Message
+			l := b.loc(m.From)
+			switch group := isGroup(m.Chat); {
+			case c.Scope&ScopeAdmin != 0 && (m.From == nil || !b.cfg.IsAdmin(m.From.ID)):
+				b.reply(m.Chat.ID, l.T("cmd.admin_only"), m.MessageID)
+			case group && c.Scope&ScopeGroup == 0:
+				b.reply(m.Chat.ID, l.T("cmd.private_only"), m.MessageID)
+			case !group && c.Scope&ScopePrivate == 0:
+				b.reply(m.Chat.ID, l.T("cmd.group_only"), m.MessageID)
+			default:
+				next(u)
+			}
+		}
+	}
+}
+
+// helpText — справка из реестра: команды, доступные в этом чате этому пользователю
+func (b *Bot) helpText(l i18n.Localizer, group, admin bool) string {
+	var sb strings.Builder
+	sb.WriteString(l.T("help.intro", "mb", b.cfg.MaxFileMB) + "\n\n" + l.T("help.commands"))
+	for _, c := range b.commands.Visible(group, admin) {
+		sb.WriteString("\n/" + c.Name)
+		if c.Args != "" {
+			sb.WriteString(" " + l.T(c.Args))
+		}
+		sb.WriteString(" — " + l.T(c.Description))
+	}
+	return sb.String()
+}
+
+// publishCommands — меню команд Telegram: личные чаты, группы и личные чаты ADMIN_IDS,
+// на языке по умолчанию и отдельно на каждом языке каталога; ошибка не мешает работе
+func (b *Bot) publishCommands() {
+	type target struct {
+		scope tgbotapi.BotCommandScope
+		cmds  []Command
+	}
+	targets := []target{
+		{tgbotapi.BotCommandScope{Type: "all_private_chats"}, b.commands.Visible(false, false)},
+		{tgbotapi.BotCommandScope{Type: "all_group_chats"}, b.commands.Visible(true, false)},
+	}
+	// меню чата заменяет меню всех личных чатов, поэтому в нём и обычные команды
+	for _, id := range b.cfg.AdminIDs {
+		targets = append(targets, target{tgbotapi.BotCommandScope{Type: "chat", ChatID: id}, b.commands.Visible(false, true)})
+	}
+	langs := []string{""}
+	for _, l := range b.texts.Langs() {
+		if l != b.texts.Fallback() {
+			langs = append(langs, l)
+		}
+	}
+	for _, t := range targets {
+		scope := t.scope
+		for _, lang := range langs {
+			cfg := tgbotapi.SetMyCommandsConfig{Commands: menu(b.texts.For(lang), t.cmds), Scope: &scope, LanguageCode: lang}
+			if _, err := b.api.Request(cfg); err != nil {
+				log.Printf("[bot] set commands failed: scope=%s chat=%d lang=%q err=%v", scope.Type, scope.ChatID, lang, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько необработанных обновлений держать на чат; сверх этого — отбрасываем
+const chatQueueLimit = 32
+
+// dispatcher — обработка обновлений: limit обработчиков разбирают чаты параллельно,
+// обновления одного чата — строго по очереди
+type dispatcher struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  map[int64][]func() // очереди чатов, у которых есть необработанное или обрабатываемое обновление
+	ready   []int64            // чаты, ждущие свободного обработчика
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newDispatcher(limit int) *dispatcher {
+	if limit <= 0 {
+		limit = 1
+	}
+	d := &dispatcher{queues: make(map[int64][]func())}
+	d.cond = sync.NewCond(&d.mu)
+	for i := 0; i < limit; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// submit — поставить обработку в очередь чата; false — очередь чата переполнена, обновление отброшено
+func (d *dispatcher) submit(chatID int64, fn func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q, active := d.queues[chatID]
+	if len(q) >= chatQueueLimit {
+		log.Printf("[bot] chat queue full, update dropped: chat=%d", chatID)
+		return false
+	}
+	d.wg.Add(1)
+	d.queues[chatID] = append(q, fn)
+	// чат, который сейчас обрабатывается, вернётся в ready сам
+	if !active {
+		d.ready = append(d.ready, chatID)
+		d.cond.Signal()
+	}
+	return true
+}
+
+// worker — брать готовый чат и выполнять по одному его обновлению; чат с остатком
+// уходит в конец ready, чтобы занятый чат не держал обработчик
+func (d *dispatcher) worker() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		for len(d.ready) == 0 && !d.stopped {
+			d.cond.Wait()
+		}
+		if d.stopped {
+			return
+		}
+		chatID := d.ready[0]
+		d.ready = d.ready[1:]
+		q := d.queues[chatID]
+		fn := q[0]
+		d.queues[chatID] = q[1:]
+		d.mu.Unlock()
+		safeCall(chatID, fn)
+		d.wg.Done()
+		d.mu.Lock()
+		if len(d.queues[chatID]) == 0 {
+			delete(d.queues, chatID)
+		} else {
+			d.ready = append(d.ready, chatID)
+			d.cond.Signal()
+		}
+	}
+}
+
+// wait — дождаться обработки всего поставленного (остановка, тесты)
+func (d *dispatcher) wait() { d.wg.Wait() }
+
+// stop — отпустить обработчики; необработанные обновления остаются невыполненными
+func (d *dispatcher) stop() {
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// safeCall — паника в обработчике не роняет бота и не останавливает очередь чата
+func safeCall(chatID int64, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[bot] handler panic: chat=%d: %v\n%s", chatID, r, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+// updateChat — ключ очереди: чат сообщения или пользователь инлайн-запроса
+func updateChat(u tgbotapi.Update) int64 {
+	switch {
+	case u.Message != nil && u.Message.Chat != nil:
+		return u.Message.Chat.ID
+	case u.CallbackQuery != nil && u.CallbackQuery.Message != nil && u.CallbackQuery.Message.Chat != nil:
+		return u.CallbackQuery.Message.Chat.ID
+	case u.CallbackQuery != nil && u.CallbackQuery.From != nil:
+		return u.CallbackQuery.From.ID
+	case u.InlineQuery != nil && u.InlineQuery.From != nil:
+		return u.InlineQuery.From.ID
+	case u.ChosenInlineResult != nil && u.ChosenInlineResult.From != nil:
+		return u.ChosenInlineResult.From.ID
+	}
+	return 0
+}
@@ -0,0 +1,187 @@
+package telegram
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// UseUsername — имя бота (api.Self.UserName) для команд /cmd@bot и упоминаний в группах
+func (b *Bot) UseUsername(name string) { b.username = strings.TrimPrefix(name, "@") }
+
+func isGroup(c *tgbotapi.Chat) bool { return c != nil && (c.IsGroup() || c.IsSuperGroup()) }
+
+// foreignCommand — команда другому боту: /cmd@other
+func (b *Bot) foreignCommand(m *tgbotapi.Message) bool {
+	if !m.IsCommand() {
+		return false
+	}
+	_, at, ok := strings.Cut(m.CommandWithAt(), "@")
+	return ok && !strings.EqualFold(at, b.username)
+}
+
+// mentioned — в тексте есть @имя_бота
+func (b *Bot) mentioned(m *tgbotapi.Message) bool {
+	return b.username != "" && strings.Contains(strings.ToLower(m.Text), "@"+strings.ToLower(b.username))
+}
+
+// chatAdmin — администратор группы (по данным Telegram) или бота (ADMIN_IDS)
+func (b *Bot) chatAdmin(chatID int64, u *tgbotapi.User) bool {
+	if u == nil {
+		return false
+	}
+	if b.cfg.IsAdmin(u.ID) {
+		return true
+	}
+	resp, err := b.api.Request(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: u.ID}})
+	if err != nil {
+		log.Printf("[bot] get chat member failed: chat=%d err=%v", chatID, err)
+		return false
+	}
+	var member tgbotapi.ChatMember
+	if err := json.Unmarshal(resp.Result, &member); err != nil {
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator()
+}
+
+// groupPrefs — настройки группы (нулевые, если не задавались или чат личный)
+func (b *Bot) groupPrefs(chatID int64) state.GroupPrefs {
+	if g := b.prefs.Get(chatID).Group; g != nil {
+		return *g
+	}
+	return state.GroupPrefs{}
+}
+
+// mayTrigger — может ли пользователь запускать загрузки в этом чате
+func (b *Bot) mayTrigger(chatID int64, u *tgbotapi.User) bool {
+	if !b.groupPrefs(chatID).AdminsOnly {
+		return true
+	}
+	return b.chatAdmin(chatID, u)
+}
+
+// chatPresets — пресеты, разрешённые в чате; без ограничений — весь реестр
+func (b *Bot) chatPresets(chatID int64) *config.Presets {
+	allowed := b.groupPrefs(chatID).Variants
+	if len(allowed) == 0 {
+		return b.presets
+	}
+	var list []config.Preset
+	for _, p := range b.presets.All() {
+		for _, id := range allowed {
+			if p.ID == id {
+				list = append(list, p)
+			}
+		}
+	}
+	// пресеты сменились и ни один не остался — не запираем группу
+	reg, err := config.NewPresets(list)
+	if err != nil || len(list) == 0 {
+		return b.presets
+	}
+	return reg
+}
+
+// groupDefault — качество группы по умолчанию, если оно ещё разрешено
+func (b *Bot) groupDefault(chatID int64) (config.Preset, bool) {
+	id := b.groupPrefs(chatID).Default
+	if id == "" {
+		return config.Preset{}, false
+	}
+	return b.chatPresets(chatID).Get(id)
+}
+
+// handleGroupCommand — /group [variants коды|all] [default код|off] [trigger all|admins]
+func (b *Bot) handleGroupCommand(m *tgbotapi.Message) {
+	l := b.loc(m.From)
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		b.reply(m.Chat.ID, b.groupText(l, m.Chat.ID)+"\n\n"+l.T("group.change"), m.MessageID)
+		return
+	}
+	if !b.chatAdmin(m.Chat.ID, m.From) {
+		b.reply(m.Chat.ID, l.T("group.admins"), m.MessageID)
+		return
+	}
+	if len(args) != 2 {
+		b.reply(m.Chat.ID, l.T("group.usage"), m.MessageID)
+		return
+	}
+
+	g := b.groupPrefs(m.Chat.ID)
+	val := strings.ToLower(args[1])
+	switch strings.ToLower(args[0]) {
+	case "variants":
+		g.Variants = nil
+		if val != "all" {
+			for _, code := range strings.Split(args[1], ",") {
+				p, ok := b.presets.ByCode(strings.TrimSpace(code))
+				if !ok {
+					b.reply(m.Chat.ID, l.T("group.unknown", "code", strconv.Quote(code), "codes", presetCodes(b.presets)), m.MessageID)
+					return
+				}
+				g.Variants = append(g.Variants, p.ID)
+			}
+		}
+	case "default":
+		g.Default = ""
+		if val != "off" {
+			p, ok := b.presets.ByCode(args[1])
+			if !ok {
+				b.reply(m.Chat.ID, l.T("group.unknown", "code", strconv.Quote(args[1]), "codes", presetCodes(b.presets)), m.MessageID)
+				return
+			}
+			g.Default = p.ID
+		}
+	case "trigger":
+		switch val {
+		case "all", "admins":
+			g.AdminsOnly = val == "admins"
+		default:
+			b.reply(m.Chat.ID, l.T("group.bad_trigger"), m.MessageID)
+			return
+		}
+	default:
+		b.reply(m.Chat.ID, l.T("group.bad_setting"), m.MessageID)
+		return
+	}
+
+	if err := b.prefs.Update(m.Chat.ID, func(u *state.UserPrefs) { u.Group = &g }); err != nil {
+		log.Printf("[bot] save prefs failed: %v", err)
+	}
+	b.reply(m.Chat.ID, b.groupText(l, m.Chat.ID), m.MessageID)
+}
+
+// groupText — текущие настройки группы
+func (b *Bot) groupText(l i18n.Localizer, chatID int64) string {
+	g := b.groupPrefs(chatID)
+	var labels []string
+	for _, p := range b.chatPresets(chatID).All() {
+		labels = append(labels, p.LabelIn(l.Lang))
+	}
+	def := l.T("group.no_default")
+	if p, ok := b.groupDefault(chatID); ok {
+		def = p.LabelIn(l.Lang)
+	}
+	who := l.T("group.who_all")
+	if g.AdminsOnly {
+		who = l.T("group.who_admins")
+	}
+	return l.T("group.summary", "variants", strings.Join(labels, ", "), "default", def, "who", who)
+}
+
+func presetCodes(p *config.Presets) string {
+	var codes []string
+	for _, pr := range p.All() {
+		codes = append(codes, pr.Code)
+	}
+	return strings.Join(codes, ", ")
+}
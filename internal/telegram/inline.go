@@ -0,0 +1,215 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/downloader"
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/queue"
+	"youtube-bot-simple/internal/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// UseFileCache — кэш file_id (по умолчанию — только в памяти)
+func (b *Bot) UseFileCache(c *state.FileCache) { b.files = c }
+
+// handleInlineQuery — «@bot ссылка»: готовые файлы из кэша сразу, остальные варианты — заглушка,
+// которая после выбора скачивается и подменяется файлом
+func (b *Bot) handleInlineQuery(q *tgbotapi.InlineQuery) {
+	l := b.loc(q.From)
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID:     q.ID,
+		IsPersonal:        true,
+		CacheTime:         10,
+		SwitchPMText:      l.T("inline.open_bot"),
+		SwitchPMParameter: "inline",
+		Results:           []interface{}{},
+	}
+	url := extractYouTubeURL(q.Query)
+	id := videoID(url)
+	if id == "" {
+		if _, err := b.api.Request(answer); err != nil {
+			log.Printf("[bot] answer inline query failed: %v", err)
+		}
+		return
+	}
+
+	sb := b.userSponsor(q.From)
+	var token string
+	for _, p := range b.inlinePresets(q.From) {
+		job := queue.Job{SponsorBlock: sb}
+		b.applyPrefs(&job, q.From, p)
+		if f, ok := b.files.Get(fileKey(id, job)); ok {
+			// подпись — по шаблону и на языке того, кто запросил, а не того, кто загрузил
+			caption, mode := "", ""
+			if !job.NoCaption {
+				res := cachedResult(f)
+				caption, mode = b.caption(l, q.From.ID, url, sponsorNote(l, res), res)
+			}
+			answer.Results = append(answer.Results, cachedInlineResult(l, "c:"+p.Code, p, f, caption, mode))
+			continue
+		}
+		if token == "" {
+			token = state.GenerateToken(12)
+			b.store.Put(token, state.Payload{URL: url, SponsorBlock: sb}, 15*time.Minute)
+		}
+		answer.Results = append(answer.Results, pendingResult(l, "d:"+token+":"+p.Code, id, url, p))
+	}
+	if _, err := b.api.Request(answer); err != nil {
+		log.Printf("[bot] answer inline query failed: %v", err)
+	}
+}
+
+// cachedInlineResult — уже загруженный файл по file_id
+func cachedInlineResult(l i18n.Localizer, id string, p config.Preset, f state.CachedFile, caption, mode string) interface{} {
+	switch f.Send {
+	case config.SendAudio:
+		r := tgbotapi.NewInlineQueryResultCachedAudio(id, f.FileID)
+		r.Caption, r.ParseMode = caption, mode
+		return r
+	case config.SendVideo:
+		r := tgbotapi.NewInlineQueryResultCachedVideo(id, f.FileID, p.LabelIn(l.Lang)+": "+f.Title)
+		r.Caption, r.ParseMode = caption, mode
+		return r
+	default:
+		r := tgbotapi.NewInlineQueryResultCachedDocument(id, f.FileID, p.LabelIn(l.Lang)+": "+f.Title)
+		r.Caption, r.ParseMode = caption, mode
+		return r
+	}
+}
+
+// cacheEntry — file_id и сведения о файле для кэша, без подписи
+func cacheEntry(fileID, send string, res *DownloadResult) state.CachedFile {
+	f := state.CachedFile{FileID: fileID, Send: send, Title: res.Title, Uploader: res.Uploader, Duration: res.Duration,
+		Width: res.Width, Height: res.Height, Size: res.Size, SponsorRemoved: res.SponsorRemoved, SponsorCut: res.SponsorCut}
+	for _, c := range res.Chapters {
+		f.Chapters = append(f.Chapters, state.Chapter{Start: c.Start, Title: c.Title})
+	}
+	return f
+}
+
+// cachedResult — сведения из кэша в виде результата загрузки: по ним собирается подпись
+func cachedResult(f state.CachedFile) *DownloadResult {
+	res := &DownloadResult{Title: f.Title, Uploader: f.Uploader, Duration: f.Duration, Width: f.Width, Height: f.Height,
+		Size: f.Size, SponsorRemoved: f.SponsorRemoved, SponsorCut: f.SponsorCut}
+	for _, c := range f.Chapters {
+		res.Chapters = append(res.Chapters, downloader.Chapter{Start: c.Start, Title: c.Title})
+	}
+	return res
+}
+
+// pendingResult — заглушка с превью ролика. Фото, а не текст: editMessageMedia меняет
+// медиа на медиа, а кнопка нужна, чтобы Telegram прислал inline_message_id
+func pendingResult(l i18n.Localizer, id, vid, url string, p config.Preset) interface{} {
+	thumb := "https://i.ytimg.com/vi/" + vid + "/hqdefault.jpg"
+	r := tgbotapi.NewInlineQueryResultPhotoWithThumb(id, thumb, thumb)
+	label := p.LabelIn(l.Lang)
+	r.Title = l.T("inline.title", "label", label)
+	r.Description = l.T("inline.description")
+	r.Caption = l.T("inline.loading", "label", label)
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(l.T("inline.open_yt"), url)))
+	r.ReplyMarkup = &kb
+	return r
+}
+
+// handleChosenInline — пользователь отправил заглушку: ставим загрузку в очередь
+func (b *Bot) handleChosenInline(ctx context.Context, c *tgbotapi.ChosenInlineResult) {
+	parts := strings.SplitN(c.ResultID, ":", 3)
+	if len(parts) != 3 || parts[0] != "d" {
+		return // файл из кэша уже отправлен
+	}
+	if c.InlineMessageID == "" {
+		log.Printf("[bot] chosen inline result without inline_message_id: %s", c.ResultID)
+		return
+	}
+	l := b.loc(c.From)
+	job := queue.Job{ChatID: c.From.ID, RequestedAt: time.Now().Unix(), InlineMessageID: c.InlineMessageID, Lang: l.Lang}
+	payload, ok := b.store.Get(parts[1])
+	p, known := b.presets.ByCode(parts[2])
+	if !ok || !known {
+		b.notify(job, l.T("inline.expired"))
+		return
+	}
+	job.URL, job.SponsorBlock = payload.URL, payload.SponsorBlock
+	b.applyPrefs(&job, c.From, p)
+
+	// трансляции и премьеры в инлайн-режиме не записываем
+	if info := b.probe(ctx, job.URL); info != nil && (info.Live() || info.Upcoming()) {
+		b.notify(job, l.T("inline.live"))
+		return
+	}
+	b.q.Enqueue(job)
+}
+
+// deliverInline — подставить загруженный файл в инлайн-сообщение
+func (b *Bot) deliverInline(job queue.Job, f state.CachedFile, caption, mode string) error {
+	var media interface{}
+	switch f.Send {
+	case config.SendAudio:
+		m := tgbotapi.NewInputMediaAudio(tgbotapi.FileID(f.FileID))
+		m.Caption, m.ParseMode = caption, mode
+		media = m
+	case config.SendVideo:
+		m := tgbotapi.NewInputMediaVideo(tgbotapi.FileID(f.FileID))
+		m.Caption, m.ParseMode = caption, mode
+		m.Width, m.Height, m.Duration = f.Width, f.Height, seconds(f.Duration)
+		m.SupportsStreaming = true
+		media = m
+	default:
+		m := tgbotapi.NewInputMediaDocument(tgbotapi.FileID(f.FileID))
+		m.Caption, m.ParseMode = caption, mode
+		media = m
+	}
+	edit := tgbotapi.EditMessageMediaConfig{BaseEdit: tgbotapi.BaseEdit{InlineMessageID: job.InlineMessageID}, Media: media}
+	_, err := b.api.Request(edit)
+	return err
+}
+
+// notify — сообщение о задаче: в инлайн-режиме правится само сообщение, иначе — ответ в чат
+func (b *Bot) notify(job queue.Job, text string) {
+	if job.InlineMessageID == "" {
+		b.replyIn(job.ChatID, job.ThreadID, text, job.ReplyTo)
+		return
+	}
+	edit := tgbotapi.EditMessageCaptionConfig{BaseEdit: tgbotapi.BaseEdit{InlineMessageID: job.InlineMessageID}, Caption: text}
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("[bot] edit inline message failed: %v", err)
+	}
+}
+
+// fileKey — ключ кэша file_id: ролик, пресет и всё, что меняет файл — режим SponsorBlock,
+// субтитры, формат аудио
+func fileKey(vid string, job queue.Job) string {
+	key := vid + ":" + string(job.Variant)
+	if sb := job.SponsorBlock; sb.Mode != queue.SponsorOff {
+		key += fmt.Sprintf(":%s=%s", sb.Mode, strings.Join(sb.Categories, ","))
+	}
+	if job.Subtitles != "" {
+		key += ":subs=" + job.Subtitles
+	}
+	if job.AudioFormat != "" && job.AudioFormat != queue.AudioMP3 {
+		key += ":fmt=" + job.AudioFormat
+	}
+	return key
+}
+
+// inlinePresets — пресеты для инлайн-ответа; пресет пользователя по умолчанию — первым
+func (b *Bot) inlinePresets(from *tgbotapi.User) []config.Preset {
+	all := b.presets.All()
+	def := b.defaultVariant(from)
+	out := make([]config.Preset, 0, len(all))
+	for _, p := range all {
+		if p.ID == def {
+			out = append([]config.Preset{p}, out...)
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
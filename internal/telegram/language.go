@@ -0,0 +1,56 @@
+package telegram
+
+import (
+	"log"
+	"strings"
+
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/queue"
+	"youtube-bot-simple/internal/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// loc — язык пользователя: выбранный через /language, иначе language_code из Telegram
+func (b *Bot) loc(u *tgbotapi.User) i18n.Localizer {
+	if u == nil {
+		return b.texts.For("")
+	}
+	if lang := b.prefs.Get(u.ID).Language; lang != "" {
+		return b.texts.For(lang)
+	}
+	return b.texts.For(u.LanguageCode)
+}
+
+// jobLoc — язык сообщений о задаче (сохранён при постановке в очередь)
+func (b *Bot) jobLoc(job queue.Job) i18n.Localizer { return b.texts.For(job.Lang) }
+
+// handleLanguageCommand — /language [код|auto]: язык бота для пользователя
+func (b *Bot) handleLanguageCommand(m *tgbotapi.Message) {
+	if m.From == nil {
+		return
+	}
+	l := b.loc(m.From)
+	arg := strings.ToLower(strings.TrimSpace(m.CommandArguments()))
+	switch arg {
+	case "":
+		name := l.T("language.name")
+		if b.prefs.Get(m.From.ID).Language == "" {
+			name = l.T("language.auto", "lang", name)
+		}
+		b.reply(m.Chat.ID, l.T("language.current", "lang", name), m.MessageID)
+		return
+	case "auto":
+		arg = ""
+	default:
+		if b.texts.Match(arg) != arg {
+			b.reply(m.Chat.ID, l.T("language.unknown", "lang", arg, "langs", strings.Join(b.texts.Langs(), ", ")), m.MessageID)
+			return
+		}
+	}
+	if err := b.prefs.Update(m.From.ID, func(u *state.UserPrefs) { u.Language = arg }); err != nil {
+		log.Printf("[bot] save prefs failed: %v", err)
+	}
+	l = b.loc(m.From)
+	b.reply(m.Chat.ID, l.T("language.saved", "lang", l.T("language.name")), m.MessageID)
+}
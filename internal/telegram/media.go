@@ -0,0 +1,129 @@
+package telegram
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func seconds(f float64) int { return int(f + 0.5) }
+
+// inputFile — файл для отправки: локальному Bot API — путь (он читает файл сам, без multipart), иначе — загрузка
+func (b *Bot) inputFile(path string) tgbotapi.RequestFileData {
+	if !b.cfg.LocalBotAPI {
+		return tgbotapi.FilePath(path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return tgbotapi.FileURL("file://" + path)
+}
+
+// sendVideo — видео с длительностью, превью и стримингом; размеры — через прямой вызов,
+// иначе Telegram рисует вертикальные Shorts квадратом
+func (b *Bot) sendVideo(chatID int64, thread, replyTo int, res *DownloadResult, caption, parseMode string) (tgbotapi.Message, error) {
+	if up, ok := b.api.(RawUploader); ok && (res.Width > 0 && res.Height > 0 || thread != 0) {
+		params := mediaParams(chatID, thread, replyTo, caption, parseMode)
+		params.AddNonZero("duration", seconds(res.Duration))
+		params.AddNonZero("width", res.Width)
+		params.AddNonZero("height", res.Height)
+		params.AddBool("supports_streaming", true)
+		return uploadRaw(up, "sendVideo", params, b.mediaFiles("video", res))
+	}
+
+	v := tgbotapi.NewVideo(chatID, b.inputFile(res.Path))
+	v.Caption, v.ParseMode = caption, parseMode
+	v.ReplyToMessageID, v.AllowSendingWithoutReply = replyTo, true
+	v.SupportsStreaming = true
+	v.Duration = seconds(res.Duration)
+	if res.Thumb != "" {
+		v.Thumb = b.inputFile(res.Thumb)
+	}
+	return b.api.Send(v)
+}
+
+// sendAudio — аудио с длительностью, названием, автором и обложкой
+func (b *Bot) sendAudio(chatID int64, thread, replyTo int, res *DownloadResult, caption, parseMode string) (tgbotapi.Message, error) {
+	if up, ok := b.api.(RawUploader); ok && thread != 0 {
+		params := mediaParams(chatID, thread, replyTo, caption, parseMode)
+		params.AddNonZero("duration", seconds(res.Duration))
+		params.AddNonEmpty("title", res.Title)
+		params.AddNonEmpty("performer", res.Uploader)
+		return uploadRaw(up, "sendAudio", params, b.mediaFiles("audio", res))
+	}
+
+	a := tgbotapi.NewAudio(chatID, b.inputFile(res.Path))
+	a.Caption, a.ParseMode = caption, parseMode
+	a.ReplyToMessageID, a.AllowSendingWithoutReply = replyTo, true
+	a.Duration = seconds(res.Duration)
+	a.Title, a.Performer = res.Title, res.Uploader
+	if res.Thumb != "" {
+		a.Thumb = b.inputFile(res.Thumb)
+	}
+	return b.api.Send(a)
+}
+
+// sendDocument — документ с превью
+func (b *Bot) sendDocument(chatID int64, thread, replyTo int, res *DownloadResult, caption, parseMode string) (tgbotapi.Message, error) {
+	if up, ok := b.api.(RawUploader); ok && thread != 0 {
+		params := mediaParams(chatID, thread, replyTo, caption, parseMode)
+		return uploadRaw(up, "sendDocument", params, b.mediaFiles("document", res))
+	}
+
+	d := tgbotapi.NewDocument(chatID, b.inputFile(res.Path))
+	d.Caption, d.ParseMode = caption, parseMode
+	d.ReplyToMessageID, d.AllowSendingWithoutReply = replyTo, true
+	if res.Thumb != "" {
+		d.Thumb = b.inputFile(res.Thumb)
+	}
+	return b.api.Send(d)
+}
+
+// mediaParams — общие поля прямого вызова; тема форума (message_thread_id) есть только здесь:
+// в конфигах библиотеки её нет
+func mediaParams(chatID int64, thread, replyTo int, caption, parseMode string) tgbotapi.Params {
+	params := tgbotapi.Params{}
+	_ = params.AddFirstValid("chat_id", chatID)
+	params.AddNonZero("message_thread_id", thread)
+	params.AddNonEmpty("caption", caption)
+	params.AddNonEmpty("parse_mode", parseMode)
+	params.AddNonZero("reply_to_message_id", replyTo)
+	params.AddBool("allow_sending_without_reply", replyTo > 0)
+	return params
+}
+
+// mediaFiles — сам файл под именем поля метода и превью
+func (b *Bot) mediaFiles(field string, res *DownloadResult) []tgbotapi.RequestFile {
+	files := []tgbotapi.RequestFile{{Name: field, Data: b.inputFile(res.Path)}}
+	if res.Thumb != "" {
+		files = append(files, tgbotapi.RequestFile{Name: "thumb", Data: b.inputFile(res.Thumb)})
+	}
+	return files
+}
+
+// uploadRaw — прямой вызов метода и разбор отправленного сообщения
+func uploadRaw(up RawUploader, endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (tgbotapi.Message, error) {
+	resp, err := up.UploadFiles(endpoint, params, files)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var msg tgbotapi.Message
+	if len(resp.Result) > 0 {
+		err = json.Unmarshal(resp.Result, &msg)
+	}
+	return msg, err
+}
+
+// fileIDOf — file_id отправленного файла для повторной отправки без загрузки
+func fileIDOf(m tgbotapi.Message) string {
+	switch {
+	case m.Video != nil:
+		return m.Video.FileID
+	case m.Audio != nil:
+		return m.Audio.FileID
+	case m.Document != nil:
+		return m.Document.FileID
+	}
+	return ""
+}
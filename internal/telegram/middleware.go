@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// slowHandler — порог, после которого обработка обновления попадает в лог
+const slowHandler = 5 * time.Second
+
+// logSlow — обработчики, которые заняли больше limit (обычно probe или getChatMember)
+func logSlow(limit time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(u *Update) {
+			start := time.Now()
+			next(u)
+			if d := time.Since(start); d > limit {
+				log.Printf("[bot] slow update: id=%d chat=%d took=%s", u.UpdateID, updateChat(u.Update), d.Round(time.Millisecond))
+			}
+		}
+	}
+}
+
+// groupFilter — в группе бот видит всю переписку: отвечаем только на свои команды,
+// ссылки и упоминания
+func (b *Bot) groupFilter(next HandlerFunc) HandlerFunc {
+	return func(u *Update) {
+		m := u.Message
+		if m == nil || !isGroup(m.Chat) {
+			next(u)
+			return
+		}
+		if b.foreignCommand(m) {
+			return
+		}
+		if u.Command == "" && extractYouTubeURL(m.Text) == "" && !b.mentioned(m) {
+			return
+		}
+		next(u)
+	}
+}
+
+// requireTrigger — запуск загрузок в группе, где это разрешено только администраторам
+func (b *Bot) requireTrigger(next HandlerFunc) HandlerFunc {
+	return func(u *Update) {
+		if m := u.Message; m != nil && !b.mayTrigger(m.Chat.ID, m.From) {
+			return
+		}
+		if c := u.CallbackQuery; c != nil && c.Message != nil && !b.mayTrigger(c.Message.Chat.ID, c.From) {
+			_, _ = b.api.Request(tgbotapi.NewCallback(c.ID, b.loc(c.From).T("trigger.admins")))
+			return
+		}
+		next(u)
+	}
+}
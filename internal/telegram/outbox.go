@@ -0,0 +1,248 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько раз повторять отправку после 429 или сбоя загрузки
+const outboxRetries = 3
+
+// Outbox — исходящие вызовы Bot API с лимитами Telegram: общий темп, темп на чат
+// (личка и группа различаются), ожидание retry_after после 429 и повтор сбоев загрузки
+type Outbox struct {
+	api Sender
+	ctx context.Context // отмена прерывает ожидание очереди и повторы
+
+	global     *pacer
+	chatEvery  time.Duration
+	groupEvery time.Duration
+
+	mu    sync.Mutex
+	chats map[int64]*pacer
+
+	sleep func(context.Context, time.Duration) error // подменяется в тестах
+}
+
+// rawOutbox — Outbox поверх API с прямыми вызовами: наружу виден и RawUploader
+type rawOutbox struct{ *Outbox }
+
+// NewOutbox — обёртка над Sender: globalPerSec сообщений в секунду всего,
+// chatPerSec — в личный чат, groupPerMin — в группу (0 — без лимита); после отмены ctx
+// ожидающие вызовы возвращают ошибку, не дожидаясь своей очереди
+func NewOutbox(ctx context.Context, api Sender, globalPerSec, chatPerSec, groupPerMin int) Sender {
+	o := &Outbox{
+		api:        api,
+		ctx:        ctx,
+		global:     &pacer{interval: every(globalPerSec, time.Second)},
+		chatEvery:  every(chatPerSec, time.Second),
+		groupEvery: every(groupPerMin, time.Minute),
+		chats:      make(map[int64]*pacer),
+		sleep:      sleep,
+	}
+	if _, ok := api.(RawUploader); ok {
+		return rawOutbox{o}
+	}
+	return o
+}
+
+func every(n int, per time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return per / time.Duration(n)
+}
+
+// sleep — пауза, которую прерывает отмена ctx
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (o *Outbox) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	chatID, paced := chatOf(c)
+	err := o.do(o.ctx, chatID, paced, isUpload(c), func() (err error) {
+		msg, err = o.api.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+func (o *Outbox) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	chatID, paced := chatOf(c)
+	err := o.do(o.ctx, chatID, paced, isUpload(c), func() (err error) {
+		resp, err = o.api.Request(c)
+		return err
+	})
+	return resp, err
+}
+
+func (o rawOutbox) UploadFiles(endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	chatID, err := strconv.ParseInt(params["chat_id"], 10, 64)
+	paced := err == nil
+	err = o.do(o.ctx, chatID, paced, len(files) > 0, func() (err error) {
+		resp, err = o.api.(RawUploader).UploadFiles(endpoint, params, files)
+		return err
+	})
+	return resp, err
+}
+
+// do — дождаться своей очереди и выполнить вызов; 429 ждём столько, сколько сказал Telegram,
+// сбои сети при загрузке файла повторяем с растущей паузой
+func (o *Outbox) do(ctx context.Context, chatID int64, paced, upload bool, call func() error) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if paced {
+			if err := o.wait(ctx, chatID); err != nil {
+				return err
+			}
+		}
+		err := call()
+		if err == nil || attempt == outboxRetries {
+			return err
+		}
+		var apiErr *tgbotapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+			pause := time.Duration(apiErr.RetryAfter) * time.Second
+			log.Printf("[outbox] flood limit: chat=%d retry after %s", chatID, pause)
+			if paced {
+				// повтор и остальные отправки в этот чат подождут в wait
+				o.chat(chatID).block(time.Now().Add(pause))
+			} else if err := o.sleep(ctx, pause); err != nil {
+				return err
+			}
+		case upload && transient(err):
+			log.Printf("[outbox] upload failed, retrying in %s: chat=%d err=%v", backoff, chatID, err)
+			if err := o.sleep(ctx, backoff); err != nil {
+				return err
+			}
+			backoff *= 2
+		default:
+			return err
+		}
+	}
+}
+
+// wait — сначала очередь чата, потом общая
+func (o *Outbox) wait(ctx context.Context, chatID int64) error {
+	if d := time.Until(o.chat(chatID).reserve()); d > 0 {
+		if err := o.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+	if d := time.Until(o.global.reserve()); d > 0 {
+		return o.sleep(ctx, d)
+	}
+	return nil
+}
+
+func (o *Outbox) chat(id int64) *pacer {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	p, ok := o.chats[id]
+	if !ok {
+		// давно молчавшие чаты не держим
+		if len(o.chats) > 10000 {
+			now := time.Now()
+			for k, v := range o.chats {
+				if v.idle(now) {
+					delete(o.chats, k)
+				}
+			}
+		}
+		interval := o.chatEvery
+		if id < 0 {
+			interval = o.groupEvery
+		}
+		p = &pacer{interval: interval}
+		o.chats[id] = p
+	}
+	return p
+}
+
+// pacer — не чаще одного вызова в interval; блокировка после 429 сдвигает ближайшее окно
+type pacer struct {
+	mu       sync.Mutex
+	next     time.Time
+	interval time.Duration
+}
+
+// reserve — занять ближайшее свободное время
+func (p *pacer) reserve() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := time.Now()
+	if p.next.After(t) {
+		t = p.next
+	}
+	p.next = t.Add(p.interval)
+	return t
+}
+
+func (p *pacer) block(until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until.After(p.next) {
+		p.next = until
+	}
+}
+
+func (p *pacer) idle(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.next.Before(now)
+}
+
+// chatOf — чат исходящего сообщения или правки; прочие вызовы (ответы на callback, getChatMember) не ограничиваем
+func chatOf(c tgbotapi.Chattable) (int64, bool) {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID, true
+	case tgbotapi.VideoConfig:
+		return v.ChatID, true
+	case tgbotapi.AudioConfig:
+		return v.ChatID, true
+	case tgbotapi.DocumentConfig:
+		return v.ChatID, true
+	case tgbotapi.EditMessageTextConfig:
+		return v.ChatID, v.InlineMessageID == ""
+	case tgbotapi.EditMessageCaptionConfig:
+		return v.ChatID, v.InlineMessageID == ""
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return v.ChatID, v.InlineMessageID == ""
+	}
+	return 0, false
+}
+
+func isUpload(c tgbotapi.Chattable) bool {
+	switch c.(type) {
+	case tgbotapi.VideoConfig, tgbotapi.AudioConfig, tgbotapi.DocumentConfig:
+		return true
+	}
+	return false
+}
+
+// transient — сбой сети или 5xx; ошибки запроса (400/403) повторять бессмысленно
+func transient(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.Code >= 500
+}
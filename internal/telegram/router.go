@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Update — обновление в обработке: контекст и разобранная команда
+type Update struct {
+	tgbotapi.Update
+	Ctx     context.Context
+	Command string // зарегистрированная команда без «/» и «@бот»; неизвестная разбирается как текст
+	Args    string
+	Thread  int // тема форума (message_thread_id); 0 — не тема
+}
+
+// HandlerFunc — обработчик обновления
+type HandlerFunc func(u *Update)
+
+// Middleware — обёртка над обработчиком (права, логирование, фильтры)
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Router — выбор обработчика по команде, полю данных кнопки или тексту; общие middleware
+// оборачивают любой найденный обработчик
+type Router struct {
+	mw        []Middleware
+	commands  map[string]HandlerFunc
+	callbacks []callbackRoute
+	texts     []textRoute
+	inline    HandlerFunc
+	chosen    HandlerFunc
+	fallback  HandlerFunc
+}
+
+type callbackRoute struct {
+	key string
+	h   HandlerFunc
+}
+
+type textRoute struct {
+	match func(u *Update) bool
+	h     HandlerFunc
+}
+
+func NewRouter() *Router { return &Router{commands: make(map[string]HandlerFunc)} }
+
+// Use — middleware в порядке вызова: первый — внешний
+func (r *Router) Use(mw ...Middleware) { r.mw = append(r.mw, mw...) }
+
+// Command — /name и /name@бот
+func (r *Router) Command(name string, h HandlerFunc) { r.commands[strings.ToLower(name)] = h }
+
+// Callback — кнопки, в данных которых есть поле key=… (t=<токен>;v=720 → «v»); пустой key — любая кнопка
+func (r *Router) Callback(key string, h HandlerFunc) {
+	r.callbacks = append(r.callbacks, callbackRoute{key: key, h: h})
+}
+
+// Text — сообщения, для которых match вернул true; проверяются в порядке регистрации
+func (r *Router) Text(match func(u *Update) bool, h HandlerFunc) {
+	r.texts = append(r.texts, textRoute{match: match, h: h})
+}
+
+func (r *Router) Inline(h HandlerFunc) { r.inline = h }
+func (r *Router) Chosen(h HandlerFunc) { r.chosen = h }
+
+// Fallback — текст, который не подошёл ни одной команде и ни одному Text
+func (r *Router) Fallback(h HandlerFunc) { r.fallback = h }
+
+// Handle — найти обработчик и вызвать его через цепочку middleware
+func (r *Router) Handle(ctx context.Context, upd tgbotapi.Update, thread int) {
+	u := &Update{Update: upd, Ctx: ctx, Thread: thread}
+	h := r.route(u)
+	if h == nil {
+		return
+	}
+	for i := len(r.mw) - 1; i >= 0; i-- {
+		h = r.mw[i](h)
+	}
+	h(u)
+}
+
+func (r *Router) route(u *Update) HandlerFunc {
+	switch {
+	case u.Message != nil:
+		m := u.Message
+		if strings.TrimSpace(m.Text) == "" {
+			return nil
+		}
+		if m.IsCommand() {
+			u.Command, u.Args = strings.ToLower(m.Command()), strings.TrimSpace(m.CommandArguments())
+			if h, ok := r.commands[u.Command]; ok {
+				return h
+			}
+			u.Command, u.Args = "", ""
+		}
+		for _, t := range r.texts {
+			if t.match(u) {
+				return t.h
+			}
+		}
+		return r.fallback
+	case u.CallbackQuery != nil:
+		for _, c := range r.callbacks {
+			if c.key == "" || callbackField(u.CallbackQuery.Data, c.key) != "" {
+				return c.h
+			}
+		}
+	case u.InlineQuery != nil:
+		return r.inline
+	case u.ChosenInlineResult != nil:
+		return r.chosen
+	}
+	return nil
+}
+
+// with — middleware только для одного маршрута
+func with(h HandlerFunc, mw ...Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
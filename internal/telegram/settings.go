@@ -0,0 +1,167 @@
+package telegram
+
+import (
+	"log"
+	"strings"
+
+	"youtube-bot-simple/internal/config"
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/queue"
+	"youtube-bot-simple/internal/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// subtitleLangs — языки субтитров в /settings по кругу; "" — без субтитров
+var subtitleLangs = []string{"", "en", "ru", "de", "es", "fr"}
+
+var audioFormats = []string{queue.AudioMP3, queue.AudioM4A}
+
+// applyPrefs — пресет и настройки пользователя в задаче: субтитры — видео, формат — аудио
+func (b *Bot) applyPrefs(job *queue.Job, from *tgbotapi.User, p config.Preset) {
+	job.Variant = queue.Variant(p.ID)
+	if from == nil {
+		return
+	}
+	u := b.prefs.Get(from.ID)
+	job.NoCaption = u.NoCaption
+	if p.Audio() {
+		job.AudioFormat = u.AudioFormat
+	} else {
+		job.Subtitles = u.Subtitles
+	}
+}
+
+// autoPreset — пресет для загрузки без клавиатуры, если пользователь включил автозагрузку
+func (b *Bot) autoPreset(from *tgbotapi.User, chatID int64) (config.Preset, bool) {
+	if from == nil {
+		return config.Preset{}, false
+	}
+	u := b.prefs.Get(from.ID)
+	if !u.Auto || u.Variant == "" {
+		return config.Preset{}, false
+	}
+	return b.chatPresets(chatID).Get(u.Variant)
+}
+
+// defaultVariant — пресет пользователя по умолчанию: отмечается на клавиатуре
+func (b *Bot) defaultVariant(from *tgbotapi.User) string {
+	if from == nil {
+		return ""
+	}
+	return b.prefs.Get(from.ID).Variant
+}
+
+// handleSettingsCommand — /settings: меню настроек, каждая строка меняет значение по кругу
+func (b *Bot) handleSettingsCommand(m *tgbotapi.Message) {
+	if m.From == nil {
+		return
+	}
+	l := b.loc(m.From)
+	msg := tgbotapi.NewMessage(m.Chat.ID, l.T("settings.title"))
+	msg.ReplyMarkup = b.settingsKeyboard(l, m.From.ID, m.Chat.ID)
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("[bot] send settings failed: %v", err)
+	}
+}
+
+func (b *Bot) settingsKeyboard(l i18n.Localizer, userID, chatID int64) tgbotapi.InlineKeyboardMarkup {
+	u := b.prefs.Get(userID)
+	onOff := func(v bool) string {
+		if v {
+			return l.T("settings.on")
+		}
+		return l.T("settings.off")
+	}
+	variant := l.T("settings.variant_none")
+	if p, ok := b.chatPresets(chatID).Get(u.Variant); ok {
+		variant = p.LabelIn(l.Lang)
+	}
+	subs := l.T("settings.off")
+	if u.Subtitles != "" {
+		subs = u.Subtitles
+	}
+	audio := u.AudioFormat
+	if audio == "" {
+		audio = queue.AudioMP3
+	}
+	lang := l.T("settings.lang_auto")
+	if u.Language != "" {
+		lang = b.texts.For(u.Language).T("language.name")
+	}
+	row := func(text, key string) []tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(text, "set="+key))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row(l.T("settings.variant", "value", variant), "variant"),
+		row(l.T("settings.auto", "value", onOff(u.Auto)), "auto"),
+		row(l.T("settings.caption", "value", onOff(!u.NoCaption)), "caption"),
+		row(l.T("settings.subs", "value", subs), "subs"),
+		row(l.T("settings.audio", "value", strings.ToUpper(audio)), "audio"),
+		row(l.T("settings.lang", "value", lang), "lang"),
+	)
+}
+
+// onSettings — кнопка меню /settings: следующее значение настройки, меню перерисовывается
+func (b *Bot) onSettings(u *Update) {
+	c := u.CallbackQuery
+	if c.From == nil || c.Message == nil {
+		return
+	}
+	chatID := c.Message.Chat.ID
+	cur := b.prefs.Get(c.From.ID)
+	key := callbackField(c.Data, "set")
+	// автозагрузке нужен пресет: без него включать нечего
+	if key == "auto" && !cur.Auto && cur.Variant == "" {
+		_, _ = b.api.Request(tgbotapi.NewCallback(c.ID, b.loc(c.From).T("settings.auto_needs_variant")))
+		return
+	}
+	// пресеты чата читаются из тех же настроек — до Update, иначе повторная блокировка
+	ids := []string{""}
+	for _, pr := range b.chatPresets(chatID).All() {
+		ids = append(ids, pr.ID)
+	}
+	langs := append([]string{""}, b.texts.Langs()...)
+	err := b.prefs.Update(c.From.ID, func(p *state.UserPrefs) {
+		switch key {
+		case "variant":
+			p.Variant = nextValue(ids, p.Variant)
+			p.Auto = p.Auto && p.Variant != ""
+		case "auto":
+			p.Auto = !p.Auto
+		case "caption":
+			p.NoCaption = !p.NoCaption
+		case "subs":
+			p.Subtitles = nextValue(subtitleLangs, p.Subtitles)
+		case "audio":
+			format := p.AudioFormat
+			if format == "" {
+				format = queue.AudioMP3
+			}
+			p.AudioFormat = nextValue(audioFormats, format)
+		case "lang":
+			p.Language = nextValue(langs, p.Language)
+		}
+	})
+	if err != nil {
+		log.Printf("[bot] save prefs failed: %v", err)
+	}
+
+	// язык мог смениться — меню и ответ уже на новом
+	l := b.loc(c.From)
+	_, _ = b.api.Request(tgbotapi.NewCallback(c.ID, l.T("settings.saved")))
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, c.Message.MessageID, l.T("settings.title"), b.settingsKeyboard(l, c.From.ID, chatID))
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("[bot] edit settings failed: %v", err)
+	}
+}
+
+// nextValue — значение после cur по кругу; неизвестное — первое
+func nextValue(list []string, cur string) string {
+	for i, v := range list {
+		if v == cur {
+			return list[(i+1)%len(list)]
+		}
+	}
+	return list[0]
+}
@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"youtube-bot-simple/internal/i18n"
+	"youtube-bot-simple/internal/queue"
+	"youtube-bot-simple/internal/sponsorblock"
+	"youtube-bot-simple/internal/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// UsePrefs — хранилище пользовательских настроек (по умолчанию — только в памяти)
+func (b *Bot) UsePrefs(p *state.Prefs) { b.prefs = p }
+
+// userSponsor — режим SponsorBlock пользователя по умолчанию
+func (b *Bot) userSponsor(from *tgbotapi.User) queue.SponsorBlock {
+	if from == nil {
+		return queue.SponsorBlock{}
+	}
+	return b.prefs.Get(from.ID).SponsorBlock
+}
+
+// handleSponsorCommand — /sponsorblock [off|remove|mark] [категории через запятую]
+func (b *Bot) handleSponsorCommand(m *tgbotapi.Message) {
+	if m.From == nil {
+		return
+	}
+	l := b.loc(m.From)
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		b.reply(m.Chat.ID, l.T("sponsor.current", "mode", humanSponsor(l, b.userSponsor(m.From)),
+			"categories", strings.Join(sponsorblock.Categories, ", ")), m.MessageID)
+		return
+	}
+
+	var sb queue.SponsorBlock
+	switch strings.ToLower(args[0]) {
+	case "off":
+	case "remove":
+		sb.Mode = queue.SponsorRemove
+	case "mark":
+		sb.Mode = queue.SponsorMark
+	default:
+		b.reply(m.Chat.ID, l.T("sponsor.bad_mode"), m.MessageID)
+		return
+	}
+	if len(args) > 1 && sb.Mode != queue.SponsorOff {
+		for _, c := range strings.Split(strings.ToLower(args[1]), ",") {
+			c = strings.TrimSpace(c)
+			if c == "" {
+				continue
+			}
+			if !sponsorblock.ValidCategory(c) {
+				b.reply(m.Chat.ID, l.T("sponsor.unknown_category", "category", strconv.Quote(c), "categories", strings.Join(sponsorblock.Categories, ", ")), m.MessageID)
+				return
+			}
+			sb.Categories = append(sb.Categories, c)
+		}
+	}
+
+	if err := b.prefs.Update(m.From.ID, func(u *state.UserPrefs) { u.SponsorBlock = sb }); err != nil {
+		log.Printf("[bot] save prefs failed: %v", err)
+	}
+	b.reply(m.Chat.ID, l.T("sponsor.saved", "mode", humanSponsor(l, sb)), m.MessageID)
+}
+
+// toggleSponsor — кнопка под клавиатурой: выкл → вырезать → главы для одной ссылки
+func (b *Bot) toggleSponsor(c *tgbotapi.CallbackQuery, token string) {
+	l := b.loc(c.From)
+	payload, ok := b.store.Get(token)
+	if !ok {
+		_, _ = b.api.Request(tgbotapi.NewCallback(c.ID, l.T("button.expired")))
+		return
+	}
+	payload.SponsorBlock.Mode = nextSponsorMode(payload.SponsorBlock.Mode)
+	b.store.Put(token, payload, 15*time.Minute)
+	_, _ = b.api.Request(tgbotapi.NewCallback(c.ID, l.T("sponsor.button", "mode", humanSponsor(l, payload.SponsorBlock))))
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(c.Message.Chat.ID, c.Message.MessageID, buildKeyboard(l, token, payload.SponsorBlock, b.chatPresets(c.Message.Chat.ID), b.defaultVariant(c.From)))
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("[bot] edit keyboard failed: %v", err)
+	}
+}
+
+// sponsorNote — строка для подписи: сколько времени вырезал yt-dlp по SponsorBlock; пусто — не вырезали
+func sponsorNote(l i18n.Localizer, res *DownloadResult) string {
+	if !res.SponsorRemoved {
+		return ""
+	}
+	if res.SponsorCut <= 0 {
+		return l.T("sponsor.none")
+	}
+	return l.T("sponsor.cut", "time", formatClock(time.Duration(res.SponsorCut*float64(time.Second))))
+}
+
+func nextSponsorMode(mode string) string {
+	switch mode {
+	case queue.SponsorOff:
+		return queue.SponsorRemove
+	case queue.SponsorRemove:
+		return queue.SponsorMark
+	default:
+		return queue.SponsorOff
+	}
+}
+
+// sponsorLabel — короткое название режима для кнопки
+func sponsorLabel(l i18n.Localizer, mode string) string {
+	switch mode {
+	case queue.SponsorRemove:
+		return l.T("sponsor.remove")
+	case queue.SponsorMark:
+		return l.T("sponsor.mark")
+	default:
+		return l.T("sponsor.off")
+	}
+}
+
+func humanSponsor(l i18n.Localizer, sb queue.SponsorBlock) string {
+	if sb.Mode == queue.SponsorOff {
+		return sponsorLabel(l, sb.Mode)
+	}
+	cats := "sponsor"
+	if len(sb.Categories) > 0 {
+		cats = strings.Join(sb.Categories, ", ")
+	}
+	return sponsorLabel(l, sb.Mode) + " (" + cats + ")"
+}
+
+// videoID — идентификатор ролика из ссылки youtu.be/…, watch?v=… или /live/…
+func videoID(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if v := u.Query().Get("v"); v != "" {
+		return v
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+// formatClock — m:ss или h:mm:ss
+func formatClock(d time.Duration) string {
+	s := int(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
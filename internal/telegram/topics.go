@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// темы форума: в типах tgbotapi v5.5 нет message_thread_id, поэтому номер темы читается
+// из сырого обновления и передаётся обработчику вместе с ним
+
+// topicMessage — поля сообщения, которых не знает библиотека
+type topicMessage struct {
+	ThreadID int  `json:"message_thread_id"`
+	IsTopic  bool `json:"is_topic_message"`
+}
+
+// topicUpdate — сообщение или сообщение под кнопкой из сырого обновления
+type topicUpdate struct {
+	UpdateID      int           `json:"update_id"`
+	Message       *topicMessage `json:"message"`
+	CallbackQuery *struct {
+		Message *topicMessage `json:"message"`
+	} `json:"callback_query"`
+}
+
+// threads — тема форума по update_id: запоминается при приёме, забирается при обработке
+type threads struct {
+	mu sync.Mutex
+	m  map[int]int
+}
+
+func newThreads() *threads { return &threads{m: make(map[int]int)} }
+
+// record — запомнить тему из сырого обновления; update_id возвращается в любом случае
+func (t *threads) record(raw []byte) (updateID int) {
+	var u topicUpdate
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return 0
+	}
+	m := u.Message
+	if m == nil && u.CallbackQuery != nil {
+		m = u.CallbackQuery.Message
+	}
+	// ветки ответов в обычной супергруппе — не темы, туда отправлять по номеру нельзя
+	if m == nil || !m.IsTopic || m.ThreadID == 0 || t == nil {
+		return u.UpdateID
+	}
+	t.mu.Lock()
+	t.m[u.UpdateID] = m.ThreadID
+	t.mu.Unlock()
+	return u.UpdateID
+}
+
+// take — тема обновления (0 — не тема форума) и забыть её
+func (t *threads) take(updateID int) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.m[updateID]
+	delete(t.m, updateID)
+	return id
+}
+
+// pollUpdates — long polling прямым getUpdates: сырой ответ нужен ради message_thread_id
+func (b *Bot) pollUpdates(ctx context.Context) tgbotapi.UpdatesChannel {
+	ch := make(chan tgbotapi.Update, 100)
+	go func() {
+		cfg := tgbotapi.NewUpdate(0)
+		cfg.Timeout = 30
+		for ctx.Err() == nil {
+			resp, err := b.api.Request(cfg)
+			var raws []json.RawMessage
+			if err == nil {
+				err = json.Unmarshal(resp.Result, &raws)
+			}
+			if err != nil {
+				log.Printf("[bot] getUpdates failed, retrying in 3s: %v", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(3 * time.Second):
+				}
+				continue
+			}
+			for _, raw := range raws {
+				// смещение двигаем и для обновления, которое не разобралось, иначе оно придёт снова
+				id := b.threads.record(raw)
+				if id >= cfg.Offset {
+					cfg.Offset = id + 1
+				}
+				var u tgbotapi.Update
+				if err := json.Unmarshal(raw, &u); err != nil {
+					log.Printf("[bot] bad update skipped: %v", err)
+					b.threads.take(id)
+					continue
+				}
+				select {
+				case ch <- u:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Webhook — приём обновлений от Telegram по HTTP(S) вместо long polling
+type Webhook struct {
+	path    string
+	secret  string
+	updates chan tgbotapi.Update
+	threads *threads // темы форума; nil — не запоминать
+}
+
+func NewWebhook(path, secret string, buffer int) *Webhook {
+	return &Webhook{path: path, secret: secret, updates: make(chan tgbotapi.Update, buffer)}
+}
+
+// Updates — тот же канал, что у pollUpdates: обработчики не знают, откуда пришло обновление
+func (w *Webhook) Updates() tgbotapi.UpdatesChannel { return w.updates }
+
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != w.path {
+		http.NotFound(rw, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if w.secret != "" && subtle.ConstantTimeCompare([]byte(got), []byte(w.secret)) != 1 {
+		log.Printf("[webhook] rejected request with bad secret from %s", r.RemoteAddr)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, 1<<20))
+	var u tgbotapi.Update
+	if err == nil {
+		err = json.Unmarshal(raw, &u)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.threads.record(raw)
+	// обработка долгая (опрос ссылки), поэтому отвечаем сразу; при переполнении Telegram повторит
+	select {
+	case w.updates <- u:
+		rw.WriteHeader(http.StatusOK)
+	default:
+		w.threads.take(u.UpdateID)
+		log.Printf("[webhook] update buffer full, asking Telegram to retry: update=%d", u.UpdateID)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// startWebhook — HTTP-сервер и setWebhook; stop снимает webhook и останавливает сервер,
+// вызывающий ждёт его до выхода, иначе процесс завершится раньше deleteWebhook
+func (b *Bot) startWebhook() (updates tgbotapi.UpdatesChannel, srvErr <-chan error, stop func(), err error) {
+	wh := NewWebhook(b.cfg.WebhookPath, b.cfg.WebhookSecret, 100)
+	wh.threads = b.threads
+	srv := &http.Server{Addr: b.cfg.WebhookListen, Handler: wh, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if b.cfg.WebhookCert != "" {
+			err = srv.ListenAndServeTLS(b.cfg.WebhookCert, b.cfg.WebhookKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	if err := b.setWebhook(); err != nil {
+		_ = srv.Close()
+		return nil, nil, nil, err
+	}
+	log.Printf("[webhook] listening on %s, path %s", b.cfg.WebhookListen, b.cfg.WebhookPath)
+
+	stop = func() {
+		// без deleteWebhook следующий запуск в режиме polling получит 409 от getUpdates
+		if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			log.Printf("[webhook] deleteWebhook failed: %v", err)
+		}
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(sctx)
+		log.Printf("[webhook] stopped")
+	}
+	return wh.Updates(), errCh, stop, nil
+}
+
+// setWebhook — через прямой вызов: в конфиге библиотеки нет secret_token
+func (b *Bot) setWebhook() error {
+	up, ok := b.api.(RawUploader)
+	if !ok {
+		wh, err := tgbotapi.NewWebhook(b.cfg.WebhookEndpoint())
+		if err != nil {
+			return err
+		}
+		_, err = b.api.Request(wh)
+		return err
+	}
+	params := tgbotapi.Params{}
+	params["url"] = b.cfg.WebhookEndpoint()
+	params.AddNonEmpty("secret_token", b.cfg.WebhookSecret)
+	var files []tgbotapi.RequestFile
+	if b.cfg.WebhookCert != "" {
+		files = append(files, tgbotapi.RequestFile{Name: "certificate", Data: tgbotapi.FilePath(b.cfg.WebhookCert)})
+	}
+	_, err := up.UploadFiles("setWebhook", params, files)
+	return err
+}